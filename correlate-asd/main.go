@@ -0,0 +1,316 @@
+// Command correlate-asd compares the dive records of many SmartTrak .asd
+// files to help with identifying the unknown fields.
+//
+// Usage:
+//
+//	correlate-asd [-size 1|2|4] [-min 0.9] [-header] [-trailer] <directory>
+//
+// All .asd files in the directory are read. For each offset of the fixed size
+// dive record, the bytes are interpreted as a little endian unsigned integer
+// of the given size and compared across all dives: constant values, counters
+// and timestamps are detected, and the Pearson correlation with the values
+// decoded by the smarttrak package is calculated.
+//
+// With -header, the unknown regions of the file headers are compared instead,
+// one byte at a time. With -trailer, checksum algorithms are tested against
+// the bytes following each dive, smarttrak.Dive.Trailer: for each algorithm and
+// trailer offset, the number of matching dives is printed.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/octo/divelogs-go/smarttrak"
+)
+
+var (
+	flagSize    = flag.Int("size", 2, "size of the values in bytes: 1, 2 or 4")
+	flagMin     = flag.Float64("min", 0.9, "minimum absolute correlation to report")
+	flagHeader  = flag.Bool("header", false, "compare the unknown regions of the file headers")
+	flagTrailer = flag.Bool("trailer", false, "test the checksum hypotheses for the dive trailers")
+)
+
+// knownValue is a value decoded by the smarttrak package.
+type knownValue struct {
+	name  string
+	value func(d *smarttrak.Dive) float64
+}
+
+var knownValues = []knownValue{
+	{"Time", func(d *smarttrak.Dive) float64 { return float64(d.Time.Unix()) }},
+	{"Sequence", func(d *smarttrak.Dive) float64 { return float64(d.Sequence) }},
+	{"Duration", func(d *smarttrak.Dive) float64 { return d.Duration.Minutes() }},
+	{"SurfaceInterval", func(d *smarttrak.Dive) float64 { return d.SurfaceInterval.Minutes() }},
+	{"MaxDepth", func(d *smarttrak.Dive) float64 { return d.MaxDepth }},
+	{"AverageDepth", func(d *smarttrak.Dive) float64 { return d.AverageDepth }},
+	{"AirTemperature", func(d *smarttrak.Dive) float64 { return d.AirTemperature }},
+	{"MinTemperature", func(d *smarttrak.Dive) float64 { return d.MinTemperature }},
+	{"MaxTemperature", func(d *smarttrak.Dive) float64 { return d.MaxTemperature }},
+	{"DecoTemperature", func(d *smarttrak.Dive) float64 { return d.DecoTemperature }},
+	{"PressureStart", func(d *smarttrak.Dive) float64 { return d.PressureStart }},
+	{"PressureEnd", func(d *smarttrak.Dive) float64 { return d.PressureEnd }},
+	{"PercentO2", func(d *smarttrak.Dive) float64 { return float64(d.PercentO2) }},
+	{"WaterType", func(d *smarttrak.Dive) float64 { return d.WaterType.Density() }},
+	{"Settings.FeatureSet", func(d *smarttrak.Dive) float64 { return float64(d.Settings.FeatureSet) }},
+	{"Settings.Settings1", func(d *smarttrak.Dive) float64 { return float64(d.Settings.Settings1) }},
+	{"Settings.Settings2", func(d *smarttrak.Dive) float64 { return float64(d.Settings.Settings2) }},
+	{"len(Profile)", func(d *smarttrak.Dive) float64 { return float64(len(d.Profile)) }},
+	{"len(Events)", func(d *smarttrak.Dive) float64 { return float64(len(d.Events)) }},
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 || (*flagSize != 1 && *flagSize != 2 && *flagSize != 4) {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: correlate-asd [flags] <directory>")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	if *flagHeader {
+		if err := compareHeaders(flag.Arg(0)); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	dives, err := readDives(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *flagTrailer {
+		matchTrailers(dives)
+		return
+	}
+	if len(dives) < 3 {
+		log.Fatalf("found %d dives, at least 3 are needed", len(dives))
+	}
+
+	// Counters are detected in chronological order.
+	sort.SliceStable(dives, func(i, j int) bool {
+		return dives[i].Time.Before(dives[j].Time)
+	})
+
+	fields := map[int]smarttrak.Field{}
+	for _, f := range smarttrak.RecordFields() {
+		for i := 0; i < f.Size; i++ {
+			fields[f.Offset+i] = f
+		}
+	}
+
+	known := make([][]float64, len(knownValues))
+	for i, kv := range knownValues {
+		for _, d := range dives {
+			known[i] = append(known[i], kv.value(d))
+		}
+	}
+
+	fmt.Printf("%d dives\n\n", len(dives))
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "offset\tfield\tclass\tdistinct\tmin\tmax\tcorrelations")
+
+	records := make([][]byte, len(dives))
+	for i, d := range dives {
+		records[i] = d.Record()
+	}
+
+	size := *flagSize
+	for off := 0; off+size <= len(records[0]); off++ {
+		values := make([]float64, len(records))
+		for i, rec := range records {
+			values[i] = float64(uintAt(rec, off, size))
+		}
+
+		field := "?"
+		if f, ok := fields[off]; ok {
+			field = fmt.Sprintf("%s (%v)", f.Name, f.Status)
+		}
+
+		var corr []string
+		for i, kv := range knownValues {
+			if r := pearson(values, known[i]); math.Abs(r) >= *flagMin {
+				corr = append(corr, fmt.Sprintf("%s %.3f", kv.name, r))
+			}
+		}
+
+		min, max := minMax(values)
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%.0f\t%.0f\t%v\n",
+			off, field, classify(values, known[0]), distinct(values), min, max, corr)
+	}
+	tw.Flush()
+}
+
+// readDives reads all dives of all .asd files in dir.
+func readDives(dir string) ([]*smarttrak.Dive, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.[aA][sS][dD]"))
+	if err != nil {
+		return nil, err
+	}
+
+	var dives []*smarttrak.Dive
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		lb, err := smarttrak.ReadLogbook(bytes.NewReader(data))
+		if err != nil {
+			log.Printf("%s: %v", path, err)
+			continue
+		}
+		dives = append(dives, lb.Dives...)
+	}
+	return dives, nil
+}
+
+// compareHeaders prints, for each byte of the unknown header regions, the
+// number of distinct values across all files in dir and the values of the
+// first files.
+func compareHeaders(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.[aA][sS][dD]"))
+	if err != nil {
+		return err
+	}
+
+	var headers []*smarttrak.Header
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		h, err := smarttrak.ReadHeader(f)
+		f.Close()
+		if err != nil {
+			log.Printf("%s: %v", path, err)
+			continue
+		}
+		headers = append(headers, h)
+	}
+	if len(headers) < 2 {
+		return fmt.Errorf("found %d headers, at least 2 are needed", len(headers))
+	}
+
+	fmt.Printf("%d files\n\n", len(headers))
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "region\toffset\tdistinct\tvalues")
+	for _, r := range []struct {
+		name  string
+		bytes func(h *smarttrak.Header) []byte
+	}{
+		{"Unknown1", func(h *smarttrak.Header) []byte { return h.Unknown1 }},
+		{"Unknown2", func(h *smarttrak.Header) []byte { return h.Unknown2 }},
+		{"Unknown3", func(h *smarttrak.Header) []byte { return h.Unknown3 }},
+	} {
+		for off := 0; off < len(r.bytes(headers[0])); off++ {
+			var values []float64
+			for _, h := range headers {
+				if b := r.bytes(h); off < len(b) {
+					values = append(values, float64(b[off]))
+				}
+			}
+			shown := values
+			if len(shown) > 8 {
+				shown = shown[:8]
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%v\n", r.name, off, distinct(values), shown)
+		}
+	}
+	return tw.Flush()
+}
+
+// uintAt returns the little endian unsigned integer of the given size at
+// data[off].
+func uintAt(data []byte, off, size int) uint64 {
+	var v uint64
+	for i := size - 1; i >= 0; i-- {
+		v = v<<8 | uint64(data[off+i])
+	}
+	return v
+}
+
+// classify returns "constant", "counter" or "timestamp" if the values, in
+// chronological order, appear to be one of those, and "varying" otherwise.
+func classify(values, times []float64) string {
+	switch {
+	case distinct(values) == 1:
+		return "constant"
+	case isCounter(values):
+		return "counter"
+	case isTimestamp(values, times):
+		return "timestamp"
+	}
+	return "varying"
+}
+
+// isCounter reports whether values increase in small steps, like the dive
+// number.
+func isCounter(values []float64) bool {
+	min, max := minMax(values)
+	return isIncreasing(values) && max-min <= 2*float64(len(values))
+}
+
+// isTimestamp reports whether values grow linearly with times, i.e. whether
+// they are timestamps in some unit and with some epoch.
+func isTimestamp(values, times []float64) bool {
+	return isIncreasing(values) && pearson(values, times) > 0.999
+}
+
+func isIncreasing(values []float64) bool {
+	for i := 1; i < len(values); i++ {
+		if values[i] < values[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+func distinct(values []float64) int {
+	seen := make(map[float64]bool)
+	for _, v := range values {
+		seen[v] = true
+	}
+	return len(seen)
+}
+
+func minMax(values []float64) (min, max float64) {
+	min, max = math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	return min, max
+}
+
+// pearson returns the Pearson correlation coefficient of x and y. It returns
+// zero if either is constant.
+func pearson(x, y []float64) float64 {
+	n := float64(len(x))
+	var sx, sy float64
+	for i := range x {
+		sx += x[i]
+		sy += y[i]
+	}
+	mx, my := sx/n, sy/n
+
+	var cov, vx, vy float64
+	for i := range x {
+		dx, dy := x[i]-mx, y[i]-my
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(vx*vy)
+}
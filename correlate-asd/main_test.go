@@ -0,0 +1,109 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPearson(t *testing.T) {
+	cases := []struct {
+		name string
+		x, y []float64
+		want float64
+	}{
+		{"identical", []float64{1, 2, 3, 4}, []float64{1, 2, 3, 4}, 1},
+		{"linear", []float64{1, 2, 3, 4}, []float64{10, 30, 50, 70}, 1},
+		{"inverse", []float64{1, 2, 3, 4}, []float64{8, 6, 4, 2}, -1},
+		{"uncorrelated", []float64{1, 2, 3, 4}, []float64{1, -1, -1, 1}, 0},
+		{"constant x", []float64{5, 5, 5, 5}, []float64{1, 2, 3, 4}, 0},
+		{"constant y", []float64{1, 2, 3, 4}, []float64{7, 7, 7, 7}, 0},
+	}
+
+	for _, tc := range cases {
+		if got := pearson(tc.x, tc.y); math.Abs(got-tc.want) > 1e-9 || math.IsNaN(got) {
+			t.Errorf("pearson(%s) = %g, want %g", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	// times are the dive times in seconds, in chronological order.
+	times := []float64{0, 3600, 90000, 180000, 183600}
+
+	cases := []struct {
+		name   string
+		values []float64
+		want   string
+	}{
+		{"constant", []float64{7, 7, 7, 7, 7}, "constant"},
+		{"counter", []float64{12, 13, 14, 16, 17}, "counter"},
+		{"timestamp", []float64{1000, 1000 + 2*3600, 1000 + 2*90000, 1000 + 2*180000, 1000 + 2*183600}, "timestamp"},
+		{"increasing in large steps", []float64{1, 100, 101, 5000, 5001}, "varying"},
+		{"varying", []float64{3, 1, 4, 1, 5}, "varying"},
+	}
+
+	for _, tc := range cases {
+		if got := classify(tc.values, times); got != tc.want {
+			t.Errorf("classify(%s) = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestIsCounter(t *testing.T) {
+	cases := []struct {
+		values []float64
+		want   bool
+	}{
+		{[]float64{1, 2, 3, 4}, true},
+		// Deleted dives leave gaps.
+		{[]float64{1, 2, 5, 8}, true},
+		{[]float64{1, 2, 3, 40}, false},
+		{[]float64{4, 3, 2, 1}, false},
+	}
+
+	for _, tc := range cases {
+		if got := isCounter(tc.values); got != tc.want {
+			t.Errorf("isCounter(%v) = %v, want %v", tc.values, got, tc.want)
+		}
+	}
+}
+
+func TestIsTimestamp(t *testing.T) {
+	times := []float64{0, 10, 20, 300, 310}
+
+	cases := []struct {
+		values []float64
+		want   bool
+	}{
+		{[]float64{0, 10, 20, 300, 310}, true},
+		// Half seconds since another epoch.
+		{[]float64{5000, 5020, 5040, 5600, 5620}, true},
+		{[]float64{1, 2, 3, 4, 5}, false},
+		{[]float64{310, 300, 20, 10, 0}, false},
+	}
+
+	for _, tc := range cases {
+		if got := isTimestamp(tc.values, times); got != tc.want {
+			t.Errorf("isTimestamp(%v) = %v, want %v", tc.values, got, tc.want)
+		}
+	}
+}
+
+func TestUintAt(t *testing.T) {
+	data := []byte{0x01, 0x02, 0x03, 0x04, 0x05}
+
+	cases := []struct {
+		off, size int
+		want      uint64
+	}{
+		{0, 1, 0x01},
+		{1, 2, 0x0302},
+		{1, 4, 0x05040302},
+	}
+
+	for _, tc := range cases {
+		if got := uintAt(data, tc.off, tc.size); got != tc.want {
+			t.Errorf("uintAt(%d, %d) = %#x, want %#x", tc.off, tc.size, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"text/tabwriter"
+
+	"github.com/octo/divelogs-go/smarttrak"
+)
+
+// trailerCheck is a hypothesis about the content of smarttrak.Dive.Trailer: a
+// checksum of the dive stored at an offset of the trailer.
+type trailerCheck struct {
+	name           string
+	algorithm      checksumAlgorithm
+	offset         int
+	timeseriesOnly bool
+}
+
+// checksumAlgorithm calculates a checksum with a fixed size.
+type checksumAlgorithm struct {
+	name string
+	size int
+	sum  func(data []byte) uint32
+}
+
+var checksumAlgorithms = []checksumAlgorithm{
+	{"crc32", 4, crc32.ChecksumIEEE},
+	{"crc16-ccitt", 2, crc16CCITT},
+	{"sum16", 2, func(data []byte) uint32 {
+		var sum uint16
+		for _, b := range data {
+			sum += uint16(b)
+		}
+		return uint32(sum)
+	}},
+	{"wordsum16", 2, func(data []byte) uint32 {
+		var sum uint16
+		for i := 0; i+1 < len(data); i += 2 {
+			sum += binary.LittleEndian.Uint16(data[i:])
+		}
+		return uint32(sum)
+	}},
+	{"xor8", 1, func(data []byte) uint32 {
+		var x byte
+		for _, b := range data {
+			x ^= b
+		}
+		return uint32(x)
+	}},
+}
+
+// crc16CCITT calculates the CRC-16/CCITT-FALSE checksum of data.
+func crc16CCITT(data []byte) uint32 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return uint32(crc)
+}
+
+// trailerChecks returns the checks for a trailer of the given size: each
+// algorithm, calculated over the record and the timeseries data or over the
+// timeseries data only, stored little endian at each aligned offset.
+func trailerChecks(trailerSize int) []trailerCheck {
+	var ret []trailerCheck
+	for _, a := range checksumAlgorithms {
+		for _, tsOnly := range []bool{false, true} {
+			data := "record+timeseries"
+			if tsOnly {
+				data = "timeseries"
+			}
+			for off := 0; off+a.size <= trailerSize; off += a.size {
+				ret = append(ret, trailerCheck{
+					name:           fmt.Sprintf("%s(%s)@%d", a.name, data, off),
+					algorithm:      a,
+					offset:         off,
+					timeseriesOnly: tsOnly,
+				})
+			}
+		}
+	}
+	return ret
+}
+
+// match reports whether trailer holds the checksum of record and timeseries.
+func (c trailerCheck) match(record, timeseries, trailer []byte) bool {
+	if c.offset+c.algorithm.size > len(trailer) {
+		return false
+	}
+	var stored uint32
+	for i := c.algorithm.size - 1; i >= 0; i-- {
+		stored = stored<<8 | uint32(trailer[c.offset+i])
+	}
+
+	data := timeseries
+	if !c.timeseriesOnly {
+		data = append(append([]byte(nil), record...), timeseries...)
+	}
+	return stored == c.algorithm.sum(data)
+}
+
+// matchTrailers prints the number of dives matching each trailer check. A
+// checksum matches all dives; a handful of matches is likely a coincidence.
+// Dives with an all zero trailer are skipped.
+func matchTrailers(dives []*smarttrak.Dive) {
+	var (
+		checks  []trailerCheck
+		counts  = map[string]int{}
+		skipped int
+	)
+	for _, d := range dives {
+		if isZero(d.Trailer) {
+			skipped++
+			continue
+		}
+		if checks == nil {
+			checks = trailerChecks(len(d.Trailer))
+		}
+		for _, c := range checks {
+			if c.match(d.Record(), d.Timeseries(), d.Trailer) {
+				counts[c.name]++
+			}
+		}
+	}
+
+	fmt.Printf("%d dives, %d with an all zero trailer\n\n", len(dives), skipped)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "check\tmatches")
+	for _, c := range checks {
+		fmt.Fprintf(tw, "%s\t%d\n", c.name, counts[c.name])
+	}
+	tw.Flush()
+}
+
+func isZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
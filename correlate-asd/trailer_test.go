@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestTrailerChecks(t *testing.T) {
+	checks := trailerChecks(8)
+	if got, want := len(checks), 2*(2+4+4+4+8); got != want {
+		t.Errorf("len(trailerChecks(8)) = %d, want %d", got, want)
+	}
+
+	record := []byte{0x01, 0x02, 0x03}
+	timeseries := []byte{0xF0, 0x01, 0xFA, 0x00}
+	for _, c := range checks {
+		data := timeseries
+		if !c.timeseriesOnly {
+			data = append(append([]byte(nil), record...), timeseries...)
+		}
+		sum := c.algorithm.sum(data)
+
+		trailer := make([]byte, 8)
+		for i := 0; i < c.algorithm.size; i++ {
+			trailer[c.offset+i] = byte(sum >> (8 * i))
+		}
+		if !c.match(record, timeseries, trailer) {
+			t.Errorf("%s: match() = false for trailer % X", c.name, trailer)
+		}
+
+		// Change a byte covered by every check.
+		changed := append([]byte(nil), timeseries...)
+		changed[1] ^= 0x40
+		if c.match(record, changed, trailer) {
+			t.Errorf("%s: match() = true after changing the timeseries data", c.name)
+		}
+	}
+}
+
+func TestCRC16CCITT(t *testing.T) {
+	if got, want := crc16CCITT([]byte("123456789")), uint32(0x29B1); got != want {
+		t.Errorf("crc16CCITT() = %#x, want %#x", got, want)
+	}
+}
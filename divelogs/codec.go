@@ -0,0 +1,228 @@
+package divelogs
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The divelogs.de format stores the local date and time of a dive without a
+// time zone. Decoder and Encoder let the caller choose the time zone in which
+// DATE and TIME are interpreted and written. xml.Unmarshal and xml.Marshal
+// use the local time zone and the time zone of the dive, respectively.
+
+// Decoder reads Data and Logbook values from an XML stream.
+type Decoder struct {
+	// Location is the time zone in which DATE and TIME are interpreted.
+	// NewDecoder sets it to time.Local.
+	Location *time.Location
+
+	dec *xml.Decoder
+	raw *rawInput
+}
+
+// NewDecoder returns a new decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	raw := &rawInput{}
+	return &Decoder{
+		Location: time.Local,
+		dec:      xml.NewDecoder(io.TeeReader(r, raw)),
+		raw:      raw,
+	}
+}
+
+// Decode reads the next XML element from the stream and stores it in v,
+// which must be a *Data or a *Logbook.
+func (d *Decoder) Decode(v interface{}) error {
+	loc := d.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	start, err := d.nextStart()
+	if err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case *Data:
+		return v.decode(d.dec, start, loc, d.raw)
+	case *Logbook:
+		return v.decode(d.dec, start, loc, d.raw)
+	}
+	return fmt.Errorf("divelogs: cannot decode into %T", v)
+}
+
+// rawInput keeps the input read by the xml.Decoder, because xml.Decoder does
+// not report whether text was written as a CDATA section. See CDATA.
+type rawInput struct {
+	buf []byte
+	// base is the input offset of buf[0].
+	base int64
+}
+
+func (r *rawInput) Write(p []byte) (int, error) {
+	r.buf = append(r.buf, p...)
+	return len(p), nil
+}
+
+// isCDATA reports whether the input at offset starts a CDATA section. It is
+// false for a nil *rawInput.
+func (r *rawInput) isCDATA(offset int64) bool {
+	const prefix = "<![CDATA["
+	if r == nil || offset < r.base || offset+int64(len(prefix)) > r.base+int64(len(r.buf)) {
+		return false
+	}
+	i := offset - r.base
+	return string(r.buf[i:i+int64(len(prefix))]) == prefix
+}
+
+// discard drops the input before offset. It does nothing for a nil
+// *rawInput.
+func (r *rawInput) discard(offset int64) {
+	if r == nil {
+		return
+	}
+	n := offset - r.base
+	if n <= 0 || n > int64(len(r.buf)) {
+		return
+	}
+	r.buf = append(r.buf[:0], r.buf[n:]...)
+	r.base = offset
+}
+
+// nextStart skips to the next start element.
+func (d *Decoder) nextStart() (xml.StartElement, error) {
+	for {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start, nil
+		}
+	}
+}
+
+// Encoder writes Data and Logbook values to an XML stream.
+type Encoder struct {
+	// Location is the time zone in which DATE and TIME are written. If nil,
+	// each dive is written in the time zone of its Time field. For dives
+	// converted from a dive computer this is usually the device's offset.
+	Location *time.Location
+	// Format selects the formatting of the document. NewEncoder sets it
+	// to Format_Go.
+	Format Format
+
+	w           io.Writer
+	enc         *xml.Encoder
+	wroteHeader bool
+}
+
+// NewEncoder returns a new encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		w:   w,
+		enc: xml.NewEncoder(w),
+	}
+}
+
+// Indent sets the indentation, see xml.Encoder.Indent. It is ignored by
+// Format_Divelogs.
+func (e *Encoder) Indent(prefix, indent string) {
+	e.enc.Indent(prefix, indent)
+}
+
+// Encode writes v, which must be a Data or a Logbook, to the stream.
+func (e *Encoder) Encode(v interface{}) error {
+	if e.Format == Format_Divelogs {
+		return e.encodeDivelogs(v)
+	}
+
+	var err error
+	switch v := v.(type) {
+	case Data:
+		err = v.encode(e.enc, xml.StartElement{}, e.Location)
+	case *Data:
+		err = v.encode(e.enc, xml.StartElement{}, e.Location)
+	case Logbook:
+		err = v.encode(e.enc, xml.StartElement{}, e.Location)
+	case *Logbook:
+		err = v.encode(e.enc, xml.StartElement{}, e.Location)
+	default:
+		return fmt.Errorf("divelogs: cannot encode %T", v)
+	}
+	if err != nil {
+		return err
+	}
+	return e.enc.Flush()
+}
+
+// encodeDivelogs writes v using Format_Divelogs. The XML declaration is only
+// written before the first value.
+func (e *Encoder) encodeDivelogs(v interface{}) error {
+	var f formatter
+	if !e.wroteHeader {
+		f.buf.WriteString(xml.Header)
+	}
+
+	switch v := v.(type) {
+	case Data:
+		f.writeData(v, e.Location)
+	case *Data:
+		f.writeData(*v, e.Location)
+	case Logbook:
+		f.writeLogbook(v, e.Location)
+	case *Logbook:
+		f.writeLogbook(*v, e.Location)
+	default:
+		return fmt.Errorf("divelogs: cannot encode %T", v)
+	}
+	if f.err != nil {
+		return f.err
+	}
+
+	if _, err := e.w.Write(f.buf.Bytes()); err != nil {
+		return err
+	}
+	e.wroteHeader = true
+	return nil
+}
+
+// ParseLocation returns the time zone described by s. It accepts "Local",
+// the name of a zone in the IANA time zone database, such as
+// "Europe/Berlin", and fixed offsets from UTC in the form "+01:00" or
+// "-0330". It returns nil for the empty string.
+func ParseLocation(s string) (*time.Location, error) {
+	switch {
+	case s == "":
+		return nil, nil
+	case s[0] == '+' || s[0] == '-':
+		return parseOffset(s)
+	}
+	return time.LoadLocation(s)
+}
+
+func parseOffset(s string) (*time.Location, error) {
+	digits := strings.Replace(s[1:], ":", "", 1)
+	if len(digits) == 2 {
+		digits += "00"
+	}
+	if len(digits) != 4 || strings.Trim(digits, "0123456789") != "" {
+		return nil, fmt.Errorf("invalid time zone offset %q", s)
+	}
+	hh, _ := strconv.Atoi(digits[:2])
+	mm, _ := strconv.Atoi(digits[2:])
+	if hh > 14 || mm > 59 {
+		return nil, fmt.Errorf("invalid time zone offset %q", s)
+	}
+
+	offset := hh*3600 + mm*60
+	if s[0] == '-' {
+		offset = -offset
+	}
+	return time.FixedZone("UTC"+s, offset), nil
+}
@@ -0,0 +1,127 @@
+package divelogs
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecoder_Location(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	cases := []struct {
+		name string
+		loc  *time.Location
+		want string
+	}{
+		{"fixed offset", time.FixedZone("", -3*3600), "2021-10-17T11:15:15-03:00"},
+		{"IANA zone", berlin, "2021-10-17T11:15:15+02:00"},
+		{"UTC", time.UTC, "2021-10-17T11:15:15Z"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f, err := os.Open("testdata/data.xml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			dec := NewDecoder(f)
+			dec.Location = c.loc
+
+			var got Data
+			if err := dec.Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if s := got.Time.Format(time.RFC3339); s != c.want {
+				t.Errorf("Decode().Time = %s, want %s", s, c.want)
+			}
+		})
+	}
+}
+
+func TestEncoder_Location(t *testing.T) {
+	// The time of the dive in the device's time zone.
+	tm := time.Date(2021, time.October, 17, 11, 15, 15, 0, time.FixedZone("", 2*3600))
+
+	cases := []struct {
+		name string
+		loc  *time.Location
+		want string
+	}{
+		{"device offset", nil, "<DATE>17.10.2021</DATE><TIME>11:15:15</TIME>"},
+		{"UTC", time.UTC, "<DATE>17.10.2021</DATE><TIME>09:15:15</TIME>"},
+		{"previous day", time.FixedZone("", -12*3600), "<DATE>16.10.2021</DATE><TIME>21:15:15</TIME>"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			enc.Location = c.loc
+
+			var lb Logbook
+			lb.Add(Data{Time: tm})
+			if err := enc.Encode(lb); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(buf.String(), c.want) {
+				t.Errorf("Encode() = %q, want it to contain %q", buf.String(), c.want)
+			}
+
+			dec := NewDecoder(&buf)
+			dec.Location = c.loc
+			if c.loc == nil {
+				dec.Location = tm.Location()
+			}
+			var got Logbook
+			if err := dec.Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Dives) != 1 || !got.Dives[0].Time.Equal(tm) {
+				t.Errorf("Decode() = %+v, want a dive at %v", got.Dives, tm)
+			}
+		})
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	cases := []struct {
+		in         string
+		wantOffset int
+		wantErr    bool
+	}{
+		{in: "UTC", wantOffset: 0},
+		{in: "+02:00", wantOffset: 2 * 3600},
+		{in: "-0330", wantOffset: -(3*3600 + 30*60)},
+		{in: "+05", wantOffset: 5 * 3600},
+		{in: "+5", wantErr: true},
+		{in: "+02:60", wantErr: true},
+		{in: "+1a:00", wantErr: true},
+		{in: "Not/AZone", wantErr: true},
+	}
+
+	for _, c := range cases {
+		loc, err := ParseLocation(c.in)
+		if gotErr := err != nil; gotErr != c.wantErr {
+			t.Errorf("ParseLocation(%q) = %v, want error %v", c.in, err, c.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if _, off := time.Date(2021, time.January, 1, 0, 0, 0, 0, loc).Zone(); off != c.wantOffset {
+			t.Errorf("ParseLocation(%q) has offset %d, want %d", c.in, off, c.wantOffset)
+		}
+	}
+
+	if loc, err := ParseLocation(""); loc != nil || err != nil {
+		t.Errorf("ParseLocation(\"\") = %v, %v, want nil, nil", loc, err)
+	}
+}
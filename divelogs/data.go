@@ -3,6 +3,7 @@ package divelogs
 import (
 	"encoding/xml"
 	"math"
+	"reflect"
 	"time"
 )
 
@@ -34,6 +35,9 @@ type Data struct {
 	ZoomLevel           int
 	SampleInterval      time.Duration
 	Samples             []Sample
+	// Extra holds the elements and attributes that are not modeled by Data.
+	// They are written back unchanged.
+	Extra Extra
 }
 
 // Sample is a single datapoint in the dive's timeseries data.
@@ -53,16 +57,30 @@ type Cylinder struct {
 }
 
 // MarshalXML implements the xml.Marshaler interface.
+//
+// DATE and TIME are written in the location of d.Time. Use an Encoder to
+// write them in a different time zone.
 func (d Data) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	return d.encode(enc, start, nil)
+}
+
+// encode writes d as a <DIVELOGSDATA> element. DATE and TIME are written in
+// loc, or in the location of d.Time if loc is nil.
+func (d Data) encode(enc *xml.Encoder, start xml.StartElement, loc *time.Location) error {
 	start.Name = xml.Name{
 		Local: "DIVELOGSDATA",
 	}
 
+	t := d.Time
+	if loc != nil {
+		t = t.In(loc)
+	}
+
 	ephemeral := data{
 		ID:                  d.ID,
 		DiveNumber:          d.DiveNumber,
-		Date:                d.Time.Format("02.01.2006"),
-		Time:                d.Time.Format("15:04:05"),
+		Date:                t.Format("02.01.2006"),
+		Time:                t.Format("15:04:05"),
 		DiveTimeSec:         int(math.Round(d.DiveDuration.Seconds())),
 		SurfaceDuration:     int(math.Round(float64(d.SurfaceDuration.Seconds()))),
 		MaxDepth:            d.MaxDepth,
@@ -99,13 +117,98 @@ func (d Data) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
 		Samples:                 d.Samples,
 	}
 
-	return enc.EncodeElement(ephemeral, start)
+	// The elements are written one by one, so that the unknown elements
+	// can be written back at their original position.
+	start.Attr = d.Extra.Attr
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := d.Extra.encode(enc, ""); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(ephemeral)
+	for _, f := range dataFields {
+		fv := v.Field(f.index)
+		if !f.omitEmpty || !fv.IsZero() {
+			if err := enc.EncodeElement(fv.Interface(), xml.StartElement{Name: xml.Name{Local: f.name}}); err != nil {
+				return err
+			}
+		}
+		if err := d.Extra.encode(enc, f.name); err != nil {
+			return err
+		}
+	}
+	if err := d.Extra.encodeOrphans(enc); err != nil {
+		return err
+	}
+
+	return enc.EncodeToken(start.End())
 }
 
 // UnmarshalXML implements the xml.Unmarshaler interface.
+//
+// DATE and TIME are interpreted in the local time zone. Use a Decoder to
+// interpret them in a different time zone.
 func (d *Data) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	return d.decode(dec, start, time.Local, nil)
+}
+
+// decode reads a <DIVELOGSDATA> element into d. DATE and TIME are interpreted
+// in loc. Unknown elements and attributes are stored in d.Extra. If raw is not
+// nil, it holds the input read by dec and is used to find CDATA sections in
+// unknown elements.
+func (d *Data) decode(dec *xml.Decoder, start xml.StartElement, loc *time.Location, raw *rawInput) error {
+	// The known elements are collected and decoded into ephemeral in a
+	// second step.
+	ns := namespaces(nil).with(start.Attr)
+	known := []xml.Token{xml.StartElement{Name: start.Name}}
+	var (
+		extra Extra
+		after string
+	)
+	extra.Attr = ns.attr(start.Attr)
+	for done := false; !done; {
+		raw.discard(dec.InputOffset())
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if knownElements[t.Name.Local] {
+				tokens, err := readElement(dec, t, ns, nil)
+				if err != nil {
+					return err
+				}
+				known = append(known, tokens...)
+				after = t.Name.Local
+				continue
+			}
+			tokens, err := readElement(dec, t, ns, raw)
+			if err != nil {
+				return err
+			}
+			extra.Elements = append(extra.Elements, Element{
+				After:  after,
+				Tokens: tokens,
+			})
+		case xml.CharData:
+			known = append(known, t.Copy())
+		case xml.Comment, xml.ProcInst, xml.Directive:
+			extra.Elements = append(extra.Elements, Element{
+				After:  after,
+				Tokens: []xml.Token{xml.CopyToken(t)},
+			})
+		case xml.EndElement:
+			known = append(known, t)
+			done = true
+		}
+	}
+
 	var ephemeral data
-	if err := dec.DecodeElement(&ephemeral, &start); err != nil {
+	if err := xml.NewTokenDecoder(&tokenReader{tokens: known}).Decode(&ephemeral); err != nil {
 		return err
 	}
 
@@ -142,9 +245,10 @@ func (d *Data) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
 		ZoomLevel:      ephemeral.ZoomLevel,
 		SampleInterval: time.Duration(ephemeral.SampleIntervalSec) * time.Second,
 		Samples:        ephemeral.Samples,
+		Extra:          extra,
 	}
 
-	t, err := time.ParseInLocation("02.01.2006 15:04:05", ephemeral.Date+" "+ephemeral.Time, time.Local)
+	t, err := time.ParseInLocation("02.01.2006 15:04:05", ephemeral.Date+" "+ephemeral.Time, loc)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,275 @@
+package divelogs
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Extra holds the parts of a <DIVELOGSDATA> element that are not modeled by
+// Data, so that they can be written back unchanged.
+//
+// Names are stored with the prefix used in the document, e.g. an
+// "xsi:noNamespaceSchemaLocation" attribute has the Local name
+// "xsi:noNamespaceSchemaLocation" and an empty Space, and the declaration of
+// the prefix is stored as the attribute "xmlns:xsi". This way they are
+// written back as they were read.
+type Extra struct {
+	// Attr holds the attributes of the <DIVELOGSDATA> element.
+	Attr []xml.Attr
+	// Elements holds the unknown child elements, comments and processing
+	// instructions in their original order.
+	Elements []Element
+}
+
+// Element is a child element, comment, processing instruction or directive
+// of <DIVELOGSDATA> that is not modeled by Data.
+type Element struct {
+	// After is the name of the known element that preceded this element,
+	// or empty if no known element preceded it. The element is written
+	// back at the same position.
+	After string
+	// Tokens holds the element, from its start element to its end element.
+	// Comments, processing instructions and directives are a single
+	// token. Text written as a CDATA section is a CDATA token, if the
+	// element has been read by a Decoder.
+	Tokens []xml.Token
+}
+
+// CDATA is text inside an unknown element that has been written as a CDATA
+// section. It is used in Element.Tokens instead of xml.CharData, so that
+// Format_Divelogs can write it back as a CDATA section. Format_Go writes it as
+// escaped text, like xml.Marshal.
+//
+// Only a Decoder creates CDATA tokens: xml.Unmarshal does not give access to
+// the input, and xml.Decoder does not distinguish CDATA sections from other
+// text.
+type CDATA []byte
+
+// Name returns the name of the element. It is empty for comments, processing
+// instructions and directives.
+func (e Element) Name() xml.Name {
+	if len(e.Tokens) == 0 {
+		return xml.Name{}
+	}
+	start, _ := e.Tokens[0].(xml.StartElement)
+	return start.Name
+}
+
+// String returns a short description of e, e.g. "element <RATING>" or
+// "comment <!-- stage -->".
+func (e Element) String() string {
+	if len(e.Tokens) == 0 {
+		return "empty element"
+	}
+	switch t := e.Tokens[0].(type) {
+	case xml.StartElement:
+		return "element <" + t.Name.Local + ">"
+	case xml.Comment:
+		return "comment <!--" + string(t) + "-->"
+	case xml.ProcInst:
+		return "processing instruction <?" + t.Target + " " + string(t.Inst) + "?>"
+	case xml.Directive:
+		return "directive <!" + string(t) + ">"
+	}
+	return fmt.Sprintf("%T", e.Tokens[0])
+}
+
+// encode writes the unknown elements that followed the known element named
+// after.
+func (x Extra) encode(enc *xml.Encoder, after string) error {
+	for _, e := range x.Elements {
+		if e.After != after {
+			continue
+		}
+		for _, tok := range e.Tokens {
+			if t, ok := tok.(CDATA); ok {
+				tok = xml.CharData(t)
+			}
+			if err := enc.EncodeToken(tok); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// encodeOrphans writes the unknown elements whose After does not name a known
+// element. This only happens for elements not created by the decoder.
+func (x Extra) encodeOrphans(enc *xml.Encoder) error {
+	for _, e := range x.Elements {
+		if e.After == "" || knownElements[e.After] {
+			continue
+		}
+		if err := (Extra{Elements: []Element{e}}).encode(enc, e.After); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readElement reads the element started by start and returns all of its
+// tokens, including start and the matching end element. Names are converted
+// to prefixed names using the namespace declarations of ns and the elements.
+// If raw is not nil, CDATA sections are returned as CDATA tokens.
+func readElement(dec *xml.Decoder, start xml.StartElement, ns namespaces, raw *rawInput) ([]xml.Token, error) {
+	scopes := []namespaces{ns.with(start.Attr)}
+	tokens := []xml.Token{scopes[0].start(start)}
+	for len(scopes) > 0 {
+		offset := dec.InputOffset()
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		ns := scopes[len(scopes)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			ns = ns.with(t.Attr)
+			scopes = append(scopes, ns)
+			tok = ns.start(t)
+		case xml.EndElement:
+			scopes = scopes[:len(scopes)-1]
+			tok = xml.EndElement{Name: ns.name(t.Name)}
+		case xml.CharData:
+			if raw.isCDATA(offset) {
+				tok = CDATA(t.Copy())
+			} else {
+				tok = t.Copy()
+			}
+		default:
+			tok = xml.CopyToken(tok)
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens, nil
+}
+
+// xmlNamespace is the namespace of the "xml" prefix, which is always
+// declared.
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// namespaces maps namespace URLs to the prefixes declared for them. The
+// default namespace maps to the empty prefix.
+//
+// xml.Decoder replaces prefixes with the namespace URL. namespaces is used to
+// undo this, because xml.Encoder does not write the original prefixes.
+type namespaces map[string]string
+
+// with returns the namespaces in scope of an element with the given
+// attributes.
+func (ns namespaces) with(attr []xml.Attr) namespaces {
+	var ret namespaces
+	for _, a := range attr {
+		if a.Name.Space != "xmlns" && !(a.Name.Space == "" && a.Name.Local == "xmlns") {
+			continue
+		}
+		if ret == nil {
+			ret = make(namespaces)
+			for url, prefix := range ns {
+				ret[url] = prefix
+			}
+		}
+		if a.Name.Space == "xmlns" {
+			ret[a.Value] = a.Name.Local
+		} else {
+			ret[a.Value] = ""
+		}
+	}
+	if ret == nil {
+		return ns
+	}
+	return ret
+}
+
+// name returns n with the namespace replaced by its prefix.
+func (ns namespaces) name(n xml.Name) xml.Name {
+	prefix, ok := ns[n.Space]
+	switch {
+	case n.Space == "":
+		return n
+	case n.Space == "xmlns":
+		prefix = "xmlns"
+	case n.Space == xmlNamespace:
+		prefix = "xml"
+	case !ok:
+		// An undeclared prefix is not replaced by the decoder.
+		prefix = n.Space
+	}
+	if prefix == "" {
+		return xml.Name{Local: n.Local}
+	}
+	return xml.Name{Local: prefix + ":" + n.Local}
+}
+
+// attr returns a copy of attr with prefixed names.
+func (ns namespaces) attr(attr []xml.Attr) []xml.Attr {
+	if len(attr) == 0 {
+		return nil
+	}
+	ret := make([]xml.Attr, len(attr))
+	for i, a := range attr {
+		ret[i] = xml.Attr{Name: ns.name(a.Name), Value: a.Value}
+	}
+	return ret
+}
+
+// start returns a copy of t with prefixed names.
+func (ns namespaces) start(t xml.StartElement) xml.StartElement {
+	return xml.StartElement{
+		Name: ns.name(t.Name),
+		Attr: ns.attr(t.Attr),
+	}
+}
+
+// tokenReader implements xml.TokenReader for a slice of tokens.
+type tokenReader struct {
+	tokens []xml.Token
+}
+
+func (r *tokenReader) Token() (xml.Token, error) {
+	if len(r.tokens) == 0 {
+		return nil, io.EOF
+	}
+	tok := r.tokens[0]
+	r.tokens = r.tokens[1:]
+	return tok, nil
+}
+
+// dataField is a child element of <DIVELOGSDATA> known to the data type.
+type dataField struct {
+	name      string
+	index     int
+	omitEmpty bool
+}
+
+// dataFields lists the fields of data in the order they are written. It is
+// derived from the struct tags, so that data remains the only definition of
+// the element names.
+var dataFields = func() []dataField {
+	var fields []dataField
+	t := reflect.TypeOf(data{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Name == "XMLName" {
+			continue
+		}
+		opts := strings.Split(f.Tag.Get("xml"), ",")
+		fields = append(fields, dataField{
+			name:      opts[0],
+			index:     i,
+			omitEmpty: len(opts) > 1 && opts[1] == "omitempty",
+		})
+	}
+	return fields
+}()
+
+// knownElements holds the names of the elements in dataFields.
+var knownElements = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, f := range dataFields {
+		m[f.name] = true
+	}
+	return m
+}()
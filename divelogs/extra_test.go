@@ -0,0 +1,142 @@
+package divelogs
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const extraXML = `<DIVELOGSDATA version="2">
+  <RATING>4</RATING>
+  <DIVELOGSID>1</DIVELOGSID>
+  <DATE>17.10.2021</DATE>
+  <TIME>11:15:15</TIME>
+  <DIVETYPE id="3"><![CDATA[Drift]]></DIVETYPE>
+  <MAXDEPTH>20.9</MAXDEPTH>
+  <CYLINDER nr="2"><SIZE>7.0</SIZE><!-- stage --></CYLINDER>
+  <SAMPLE><DEPTH>1.19</DEPTH></SAMPLE>
+  <NEWFIELD/>
+</DIVELOGSDATA>`
+
+func TestExtra(t *testing.T) {
+	var d Data
+	if err := xml.Unmarshal([]byte(extraXML), &d); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.ID != 1 || d.MaxDepth != 20.9 || len(d.Samples) != 1 {
+		t.Errorf("xml.Unmarshal() = %+v, known fields are not set", d)
+	}
+
+	type element struct {
+		Name, After string
+	}
+	var got []element
+	for _, e := range d.Extra.Elements {
+		got = append(got, element{e.Name().Local, e.After})
+	}
+	want := []element{
+		{"RATING", ""},
+		{"DIVETYPE", "TIME"},
+		{"CYLINDER", "MAXDEPTH"},
+		{"NEWFIELD", "SAMPLE"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Extra.Elements differ (-want/+got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]xml.Attr{{Name: xml.Name{Local: "version"}, Value: "2"}}, d.Extra.Attr); diff != "" {
+		t.Errorf("Extra.Attr differs (-want/+got):\n%s", diff)
+	}
+
+	// Changing a known field must not affect the unknown elements.
+	d.MaxDepth = 21.5
+	data, err := xml.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(data)
+	t.Logf("xml.Marshal() = %q", s)
+
+	for _, want := range []string{
+		`<DIVELOGSDATA version="2"><RATING>4</RATING><DIVELOGSID>1</DIVELOGSID>`,
+		`<TIME>11:15:15</TIME><DIVETYPE id="3">Drift</DIVETYPE>`,
+		`<MAXDEPTH>21.5</MAXDEPTH><CYLINDER nr="2"><SIZE>7.0</SIZE><!-- stage --></CYLINDER>`,
+		`</SAMPLE><NEWFIELD></NEWFIELD></DIVELOGSDATA>`,
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("xml.Marshal() = %q, want it to contain %q", s, want)
+		}
+	}
+
+	var got2 Data
+	if err := xml.Unmarshal(data, &got2); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(d, got2); diff != "" {
+		t.Errorf("round trip differs (-want/+got):\n%s", diff)
+	}
+}
+
+func TestExtra_Namespaces(t *testing.T) {
+	const in = `<DIVELOGSDATA xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:noNamespaceSchemaLocation="divelogs.xsd">
+  <!-- exported by test -->
+  <DIVELOGSID>1</DIVELOGSID>
+  <DATE>17.10.2021</DATE>
+  <TIME>11:15:15</TIME>
+  <?divelogs hint?>
+  <ext:INFO xmlns:ext="urn:example" ext:id="7" xml:lang="en"><ext:NOTE xsi:nil="true"/></ext:INFO>
+</DIVELOGSDATA>`
+
+	var d Data
+	if err := xml.Unmarshal([]byte(in), &d); err != nil {
+		t.Fatal(err)
+	}
+
+	wantAttr := []xml.Attr{
+		{Name: xml.Name{Local: "xmlns:xsi"}, Value: "http://www.w3.org/2001/XMLSchema-instance"},
+		{Name: xml.Name{Local: "xsi:noNamespaceSchemaLocation"}, Value: "divelogs.xsd"},
+	}
+	if diff := cmp.Diff(wantAttr, d.Extra.Attr); diff != "" {
+		t.Errorf("Extra.Attr differs (-want/+got):\n%s", diff)
+	}
+
+	var got []string
+	for _, e := range d.Extra.Elements {
+		got = append(got, e.String()+" after "+e.After)
+	}
+	want := []string{
+		"comment <!-- exported by test --> after ",
+		"processing instruction <?divelogs hint?> after TIME",
+		"element <ext:INFO> after TIME",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Extra.Elements differ (-want/+got):\n%s", diff)
+	}
+
+	data, err := xml.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(data)
+	t.Logf("xml.Marshal() = %q", s)
+
+	for _, want := range []string{
+		`<DIVELOGSDATA xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:noNamespaceSchemaLocation="divelogs.xsd"><!-- exported by test --><DIVELOGSID>1</DIVELOGSID>`,
+		`<TIME>11:15:15</TIME><?divelogs hint?>`,
+		`<ext:INFO xmlns:ext="urn:example" ext:id="7" xml:lang="en"><ext:NOTE xsi:nil="true"></ext:NOTE></ext:INFO>`,
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("xml.Marshal() = %q, want it to contain %q", s, want)
+		}
+	}
+
+	var got2 Data
+	if err := xml.Unmarshal(data, &got2); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(d, got2); diff != "" {
+		t.Errorf("round trip differs (-want/+got):\n%s", diff)
+	}
+}
@@ -0,0 +1,252 @@
+package divelogs
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format selects how an Encoder formats the XML document.
+type Format int
+
+const (
+	// Format_Go uses the formatting of encoding/xml, like xml.Marshal.
+	// Empty strings and coordinates are left out and numbers are written
+	// with as few digits as possible.
+	Format_Go Format = iota
+	// Format_Divelogs mimics the files exported by divelogs.de: the
+	// document starts with an XML declaration, elements are indented by
+	// two spaces and written in the order used by divelogs.de, numbers
+	// have a fixed number of decimals per field, unset values are written
+	// as empty elements and strings are always written as CDATA sections.
+	// Re-encoding an exported file read with a Decoder reproduces it
+	// byte for byte. Elements not modeled by Data are indented like the
+	// known elements, and empty ones are written as <NAME/>.
+	Format_Divelogs
+)
+
+// formatter writes the XML document for Format_Divelogs. encoding/xml is not
+// used, because it cannot write empty elements as <NAME/>.
+type formatter struct {
+	buf   bytes.Buffer
+	depth int
+	err   error
+}
+
+func (f *formatter) indent() {
+	f.buf.WriteString(strings.Repeat("  ", f.depth))
+}
+
+func (f *formatter) start(name string, attr []xml.Attr) {
+	f.indent()
+	f.buf.WriteString("<" + name)
+	f.attr(attr)
+	f.buf.WriteString(">\n")
+	f.depth++
+}
+
+// attr writes attributes with prefixed names, see Extra.
+func (f *formatter) attr(attr []xml.Attr) {
+	ns := namespaces(nil).with(attr)
+	for _, a := range attr {
+		f.buf.WriteString(" " + ns.name(a.Name).Local + `="`)
+		xml.EscapeText(&f.buf, []byte(a.Value))
+		f.buf.WriteString(`"`)
+	}
+}
+
+func (f *formatter) end(name string) {
+	f.depth--
+	f.indent()
+	f.buf.WriteString("</" + name + ">\n")
+}
+
+// element writes an element with a text value, or an empty element if value
+// is empty.
+func (f *formatter) element(name, value string) {
+	f.indent()
+	if value == "" {
+		f.buf.WriteString("<" + name + "/>\n")
+		return
+	}
+	f.buf.WriteString("<" + name + ">")
+	xml.EscapeText(&f.buf, []byte(value))
+	f.buf.WriteString("</" + name + ">\n")
+}
+
+// cdata writes an element containing a CDATA section.
+func (f *formatter) cdata(name, value string) {
+	f.indent()
+	f.buf.WriteString("<" + name + ">" + cdataSection(value) + "</" + name + ">\n")
+}
+
+// cdataSection returns value as a CDATA section.
+func cdataSection(value string) string {
+	// "]]>" cannot occur inside a CDATA section, so it is split across two.
+	value = strings.ReplaceAll(value, "]]>", "]]]]><![CDATA[>")
+	return "<![CDATA[" + value + "]]>"
+}
+
+// textEscaper escapes text in unknown elements. Unlike xml.EscapeText, it
+// keeps line breaks, so that the formatting of the element is retained.
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// unknown writes an unknown element. Its content is written unchanged,
+// including whitespace and CDATA sections, except that elements without
+// content are written as <NAME/>.
+func (f *formatter) unknown(e Element) {
+	if f.err != nil {
+		return
+	}
+	f.indent()
+	for i := 0; i < len(e.Tokens); i++ {
+		switch t := e.Tokens[i].(type) {
+		case xml.StartElement:
+			name := namespaces(nil).with(t.Attr).name(t.Name).Local
+			f.buf.WriteString("<" + name)
+			f.attr(t.Attr)
+			if i+1 < len(e.Tokens) {
+				if end, ok := e.Tokens[i+1].(xml.EndElement); ok && end.Name == t.Name {
+					f.buf.WriteString("/>")
+					i++
+					continue
+				}
+			}
+			f.buf.WriteString(">")
+		case xml.EndElement:
+			f.buf.WriteString("</" + t.Name.Local + ">")
+		case xml.CharData:
+			textEscaper.WriteString(&f.buf, string(t))
+		case CDATA:
+			f.buf.WriteString(cdataSection(string(t)))
+		case xml.Comment:
+			f.buf.WriteString("<!--" + string(t) + "-->")
+		case xml.ProcInst:
+			f.buf.WriteString("<?" + t.Target)
+			if len(t.Inst) != 0 {
+				f.buf.WriteString(" " + string(t.Inst))
+			}
+			f.buf.WriteString("?>")
+		case xml.Directive:
+			f.buf.WriteString("<!" + string(t) + ">")
+		default:
+			f.err = fmt.Errorf("divelogs: cannot encode token of type %T", t)
+			return
+		}
+	}
+	f.buf.WriteString("\n")
+}
+
+// writeData writes d in the format of divelogs.de. See Data.encode for the
+// meaning of loc.
+func (f *formatter) writeData(d Data, loc *time.Location) {
+	t := d.Time
+	if loc != nil {
+		t = t.In(loc)
+	}
+	doubles := "0"
+	if d.Cylinder.Doubles {
+		doubles = "1"
+	}
+
+	// unknown writes the unknown elements that followed the element
+	// named after. Elements without a known predecessor are written last.
+	unknown := func(after string) {
+		for _, e := range d.Extra.Elements {
+			orphan := e.After != "" && !knownElements[e.After]
+			if e.After == after || (orphan && after == "DIVELOGSDATA") {
+				f.unknown(e)
+			}
+		}
+	}
+
+	f.start("DIVELOGSDATA", d.Extra.Attr)
+	unknown("")
+
+	for _, e := range []struct {
+		name, value string
+		cdata       bool
+	}{
+		{name: "DIVELOGSDIVENUMBER", value: strconv.Itoa(d.DiveNumber)},
+		{name: "DIVELOGSID", value: strconv.Itoa(d.ID)},
+		{name: "DATE", value: t.Format("02.01.2006")},
+		{name: "TIME", value: t.Format("15:04:05")},
+		{name: "DIVETIMESEC", value: strconv.Itoa(int(math.Round(d.DiveDuration.Seconds())))},
+		{name: "SURFACETIME", value: strconv.Itoa(int(math.Round(d.SurfaceDuration.Seconds())))},
+		{name: "MAXDEPTH", value: formatDepth(d.MaxDepth)},
+		{name: "MEANDEPTH", value: formatDepth(d.MeanDepth)},
+		{name: "LOCATION", value: d.Location, cdata: true},
+		{name: "SITE", value: d.Site, cdata: true},
+		{name: "WEATHER", value: d.Weather, cdata: true},
+		{name: "WATERVIZIBILITY", value: d.Visibility, cdata: true}, // sic
+		{name: "AIRTEMP", value: formatFixed(d.AirTemperature, 1)},
+		{name: "WATERTEMPMAXDEPTH", value: formatFixed(d.MaxDepthTemperature, 1)},
+		{name: "WATERTEMPATEND", value: formatFixed(d.DiveEndTemperature, 1)},
+		{name: "PARTNER", value: d.Partner, cdata: true},
+		{name: "BOATNAME", value: d.Boat, cdata: true},
+		{name: "CYLINDERNAME", value: d.Cylinder.Name, cdata: true},
+		{name: "CYLINDERDESCRIPTION", value: d.Cylinder.Description, cdata: true},
+		{name: "DBLTANK", value: doubles},
+		{name: "CYLINDERSIZE", value: formatFixed(d.Cylinder.Size, 2)},
+		{name: "CYLINDERSTARTPRESSURE", value: formatFixed(d.Cylinder.StartPressure, 2)},
+		{name: "CYLINDERENDPRESSURE", value: formatFixed(d.Cylinder.EndPressure, 2)},
+		{name: "WORKINGPRESSURE", value: formatOptional(d.Cylinder.WorkingPressure, 2)},
+		{name: "WEIGHT", value: formatFixed(d.Weight, 2)},
+		{name: "O2PCT", value: formatFixed(d.O2Percent, 1)},
+		{name: "HEPCT", value: formatOptional(d.HEPercent, 1)},
+		{name: "LOGNOTES", value: d.LogNotes, cdata: true},
+		{name: "LAT", value: formatFixed(d.Latitude, 6)},
+		{name: "LNG", value: formatFixed(d.Longitude, 6)},
+		{name: "GOOGLEMAPSZOOMLEVEL", value: strconv.Itoa(d.ZoomLevel)},
+		{name: "SAMPLEINTERVAL", value: strconv.Itoa(int(math.Round(d.SampleInterval.Seconds())))},
+	} {
+		if e.cdata {
+			f.cdata(e.name, e.value)
+		} else {
+			f.element(e.name, e.value)
+		}
+		unknown(e.name)
+	}
+
+	for _, s := range d.Samples {
+		f.start("SAMPLE", nil)
+		f.element("DEPTH", formatDepth(s.Depth))
+		f.end("SAMPLE")
+	}
+	unknown("SAMPLE")
+	unknown("DIVELOGSDATA")
+
+	f.end("DIVELOGSDATA")
+}
+
+// writeLogbook writes the dives of l inside a <DIVELOGSLOGBOOK> element.
+func (f *formatter) writeLogbook(l Logbook, loc *time.Location) {
+	f.start("DIVELOGSLOGBOOK", nil)
+	for _, d := range l.Dives {
+		f.writeData(d, loc)
+	}
+	f.end("DIVELOGSLOGBOOK")
+}
+
+// formatDepth formats depths with as few digits as necessary, e.g. "0" and
+// "20.9".
+func formatDepth(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// formatFixed formats v with a fixed number of decimals, e.g. "0.00".
+func formatFixed(v float64, decimals int) string {
+	return strconv.FormatFloat(v, 'f', decimals, 64)
+}
+
+// formatOptional is like formatFixed, but returns the empty string for zero.
+func formatOptional(v float64, decimals int) string {
+	if v == 0 {
+		return ""
+	}
+	return formatFixed(v, decimals)
+}
@@ -0,0 +1,169 @@
+package divelogs
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFormatDivelogs(t *testing.T) {
+	for _, file := range []string{
+		"testdata/data.xml",
+		// extra.xml contains namespaced attributes, a comment, a
+		// processing instruction and unknown elements with CDATA
+		// sections.
+		"testdata/extra.xml",
+	} {
+		t.Run(file, func(t *testing.T) {
+			want, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var d Data
+			if err := NewDecoder(bytes.NewReader(want)).Decode(&d); err != nil {
+				t.Fatal(err)
+			}
+
+			var got bytes.Buffer
+			enc := NewEncoder(&got)
+			enc.Format = Format_Divelogs
+			if err := enc.Encode(d); err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(string(want), got.String()); diff != "" {
+				t.Errorf("Encode() differs from %s (-want/+got):\n%s", file, diff)
+			}
+		})
+	}
+}
+
+func TestFormatDivelogs_Unset(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.Format = Format_Divelogs
+	if err := enc.Encode(Logbook{Dives: []Data{{}, {}}}); err != nil {
+		t.Fatal(err)
+	}
+	s := buf.String()
+	t.Logf("Encode() = %q", s)
+
+	for _, want := range []string{
+		"    <WORKINGPRESSURE/>\n",
+		"    <HEPCT/>\n",
+		"    <LAT>0.000000</LAT>\n",
+		"    <LOGNOTES><![CDATA[]]></LOGNOTES>\n",
+	} {
+		if n := strings.Count(s, want); n != 2 {
+			t.Errorf("Encode() contains %q %d times, want 2", want, n)
+		}
+	}
+	if n := strings.Count(s, xml.Header); n != 1 {
+		t.Errorf("Encode() contains the XML declaration %d times, want 1", n)
+	}
+}
+
+func TestFormatDivelogs_Extra(t *testing.T) {
+	var want Data
+	if err := xml.Unmarshal([]byte(extraXML), &want); err != nil {
+		t.Fatal(err)
+	}
+	want.LogNotes = "contains ]]> in the text"
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.Format = Format_Divelogs
+	if err := enc.Encode(want); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Encode() = %q", buf.String())
+
+	var got Data
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("round trip differs (-want/+got):\n%s", diff)
+	}
+}
+
+func TestFormatDivelogs_Namespaces(t *testing.T) {
+	const xsi = "http://www.w3.org/2001/XMLSchema-instance"
+	d := Data{
+		Extra: Extra{
+			Attr: []xml.Attr{
+				{Name: xml.Name{Space: "xmlns", Local: "xsi"}, Value: xsi},
+				{Name: xml.Name{Space: xsi, Local: "noNamespaceSchemaLocation"}, Value: "divelogs.xsd"},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.Format = Format_Divelogs
+	if err := enc.Encode(d); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<DIVELOGSDATA xmlns:xsi="` + xsi + `" xsi:noNamespaceSchemaLocation="divelogs.xsd">`
+	if s := buf.String(); !strings.Contains(s, want) {
+		t.Errorf("Encode() = %q, want it to contain %q", s, want)
+	}
+}
+
+func TestFormatDivelogs_EditedExtra(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/extra.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var d Data
+	if err := NewDecoder(bytes.NewReader(data)).Decode(&d); err != nil {
+		t.Fatal(err)
+	}
+
+	var divetype *Element
+	for i, e := range d.Extra.Elements {
+		if e.Name().Local == "DIVETYPE" {
+			divetype = &d.Extra.Elements[i]
+		}
+	}
+	if divetype == nil {
+		t.Fatal("DIVETYPE not found in Extra.Elements")
+	}
+	want := []xml.Token{
+		xml.StartElement{Name: xml.Name{Local: "DIVETYPE"}, Attr: []xml.Attr{{Name: xml.Name{Local: "id"}, Value: "3"}}},
+		CDATA("Drift & <Wall>"),
+		xml.EndElement{Name: xml.Name{Local: "DIVETYPE"}},
+	}
+	if diff := cmp.Diff(want, divetype.Tokens); diff != "" {
+		t.Errorf("DIVETYPE tokens differ (-want/+got):\n%s", diff)
+	}
+
+	divetype.Tokens[1] = CDATA("Wreck")
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.Format = Format_Divelogs
+	if err := enc.Encode(d); err != nil {
+		t.Fatal(err)
+	}
+	if s, want := buf.String(), `<DIVETYPE id="3"><![CDATA[Wreck]]></DIVETYPE>`; !strings.Contains(s, want) {
+		t.Errorf("Encode() = %q, want it to contain %q", s, want)
+	}
+
+	// Format_Go writes CDATA as text.
+	data, err = xml.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, want := string(data), `<DIVETYPE id="3">Wreck</DIVETYPE>`; !strings.Contains(s, want) {
+		t.Errorf("xml.Marshal() = %q, want it to contain %q", s, want)
+	}
+}
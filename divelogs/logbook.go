@@ -0,0 +1,147 @@
+package divelogs
+
+import (
+	"encoding/xml"
+	"sort"
+	"time"
+)
+
+// Logbook is a sequence of dives stored in a single XML document.
+//
+// divelogs.de exports each dive as a document of its own. The
+// <DIVELOGSLOGBOOK> root element holding the dives is invented by this
+// package; divelogs.de does not produce it.
+//
+// Dives are kept ordered by DiveNumber and, for dives with the same number,
+// by Time.
+type Logbook struct {
+	Dives []Data
+}
+
+// Add inserts d into the logbook, keeping the dives ordered. If d has a
+// non-zero ID and the logbook already contains a dive with that ID, the
+// existing dive is replaced.
+func (l *Logbook) Add(d Data) {
+	if d.ID != 0 {
+		l.Remove(d.ID)
+	}
+
+	i := sort.Search(len(l.Dives), func(i int) bool {
+		return less(d, l.Dives[i])
+	})
+
+	l.Dives = append(l.Dives, Data{})
+	copy(l.Dives[i+1:], l.Dives[i:])
+	l.Dives[i] = d
+}
+
+// Remove removes the dive with the given ID from the logbook. It reports
+// whether a dive was removed. Dives without an ID, i.e. with ID zero, cannot
+// be removed by ID.
+func (l *Logbook) Remove(id int) bool {
+	if id == 0 {
+		return false
+	}
+	for i, d := range l.Dives {
+		if d.ID == id {
+			l.Dives = append(l.Dives[:i], l.Dives[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the dive with the given ID. Zero is not a valid ID, so
+// Lookup(0) always returns false.
+func (l *Logbook) Lookup(id int) (Data, bool) {
+	if id == 0 {
+		return Data{}, false
+	}
+	for _, d := range l.Dives {
+		if d.ID == id {
+			return d, true
+		}
+	}
+	return Data{}, false
+}
+
+// Sort restores the order of the dives. It is only needed after modifying
+// Dives directly.
+func (l *Logbook) Sort() {
+	sort.SliceStable(l.Dives, func(i, j int) bool {
+		return less(l.Dives[i], l.Dives[j])
+	})
+}
+
+func less(a, b Data) bool {
+	if a.DiveNumber != b.DiveNumber {
+		return a.DiveNumber < b.DiveNumber
+	}
+	return a.Time.Before(b.Time)
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+//
+// The dives are written as <DIVELOGSDATA> elements inside a <DIVELOGSLOGBOOK>
+// element.
+func (l Logbook) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	return l.encode(enc, start, nil)
+}
+
+// encode writes the logbook. See Data.encode for the meaning of loc.
+func (l Logbook) encode(enc *xml.Encoder, start xml.StartElement, loc *time.Location) error {
+	start.Name = xml.Name{
+		Local: "DIVELOGSLOGBOOK",
+	}
+
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	for _, d := range l.Dives {
+		if err := d.encode(enc, xml.StartElement{}, loc); err != nil {
+			return err
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+// UnmarshalXML implements the xml.Unmarshaler interface.
+//
+// All <DIVELOGSDATA> children of the start element are decoded, regardless
+// of the name of the start element itself. Other elements are ignored.
+func (l *Logbook) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	return l.decode(dec, start, time.Local, nil)
+}
+
+// decode reads the logbook. See Data.decode for the meaning of loc and raw.
+func (l *Logbook) decode(dec *xml.Decoder, start xml.StartElement, loc *time.Location, raw *rawInput) error {
+	*l = Logbook{}
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "DIVELOGSDATA" {
+				if err := dec.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var d Data
+			if err := d.decode(dec, t, loc, raw); err != nil {
+				return err
+			}
+			l.Dives = append(l.Dives, d)
+		case xml.EndElement:
+			l.Sort()
+			return nil
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package divelogs
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLogbook(t *testing.T) {
+	start := time.Date(2021, time.October, 17, 11, 15, 15, 0, time.Local)
+
+	var lb Logbook
+	lb.Add(Data{ID: 3, DiveNumber: 13, Time: start.Add(2 * time.Hour)})
+	lb.Add(Data{ID: 1, DiveNumber: 12, Time: start})
+	lb.Add(Data{ID: 2, DiveNumber: 12, Time: start.Add(time.Hour)})
+	lb.Add(Data{ID: 1, DiveNumber: 11, Time: start, Site: "Turm"})
+
+	var gotIDs []int
+	for _, d := range lb.Dives {
+		gotIDs = append(gotIDs, d.ID)
+	}
+	if diff := cmp.Diff([]int{1, 2, 3}, gotIDs); diff != "" {
+		t.Errorf("Logbook.Add: IDs differ (-want/+got):\n%s", diff)
+	}
+
+	if d, ok := lb.Lookup(1); !ok || d.Site != "Turm" {
+		t.Errorf("Logbook.Lookup(1) = %+v, %v, want the replaced dive", d, ok)
+	}
+	if !lb.Remove(2) {
+		t.Error("Logbook.Remove(2) = false, want true")
+	}
+	if _, ok := lb.Lookup(2); ok {
+		t.Error("Logbook.Lookup(2) found a removed dive")
+	}
+
+	// Dives without an ID, e.g. converted from a dive computer, must not
+	// be found by ID.
+	lb.Add(Data{DiveNumber: 14, Time: start.Add(3 * time.Hour)})
+	if _, ok := lb.Lookup(0); ok {
+		t.Error("Logbook.Lookup(0) = true, want false")
+	}
+	if lb.Remove(0) {
+		t.Error("Logbook.Remove(0) = true, want false")
+	}
+	lb.Dives = lb.Dives[:len(lb.Dives)-1]
+
+	data, err := xml.Marshal(lb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("xml.Marshal(lb) = %q", string(data))
+
+	var got Logbook
+	if err := xml.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(lb, got); diff != "" {
+		t.Errorf("xml.Unmarshal: results differ (-want/+got):\n%s", diff)
+	}
+}
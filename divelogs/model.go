@@ -0,0 +1,186 @@
+package divelogs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/octo/divelogs-go/divemodel"
+)
+
+// ToModel converts d to the format-neutral dive model. The returned report
+// lists the fields that could not be converted.
+func ToModel(d Data) (*divemodel.Dive, divemodel.Report) {
+	m := &divemodel.Dive{
+		Number:          d.DiveNumber,
+		Time:            d.Time,
+		Duration:        d.DiveDuration,
+		SurfaceInterval: d.SurfaceDuration,
+		MaxDepth:        d.MaxDepth,
+		MeanDepth:       d.MeanDepth,
+		Temperatures: divemodel.Temperatures{
+			Air:      d.AirTemperature,
+			MaxDepth: d.MaxDepthTemperature,
+			End:      d.DiveEndTemperature,
+		},
+		Location: divemodel.Location{
+			Name:      d.Location,
+			Site:      d.Site,
+			Latitude:  d.Latitude,
+			Longitude: d.Longitude,
+		},
+		Conditions: divemodel.Conditions{
+			Weather:    d.Weather,
+			Visibility: d.Visibility,
+		},
+		Partner: d.Partner,
+		Boat:    d.Boat,
+		Weight:  d.Weight,
+		Notes:   d.LogNotes,
+		Cylinders: []divemodel.Cylinder{{
+			Name:            d.Cylinder.Name,
+			Description:     d.Cylinder.Description,
+			Doubles:         d.Cylinder.Doubles,
+			Size:            d.Cylinder.Size,
+			WorkingPressure: d.Cylinder.WorkingPressure,
+			StartPressure:   d.Cylinder.StartPressure,
+			EndPressure:     d.Cylinder.EndPressure,
+			Gas: divemodel.Gas{
+				PercentO2: d.O2Percent,
+				PercentHe: d.HEPercent,
+			},
+		}},
+		SampleInterval: d.SampleInterval,
+	}
+
+	for i, s := range d.Samples {
+		m.Samples = append(m.Samples, divemodel.Sample{
+			Time:  time.Duration(i) * d.SampleInterval,
+			Depth: s.Depth,
+		})
+	}
+
+	var r divemodel.Report
+	if d.ID != 0 {
+		r.Add(divemodel.ReportKind_Dropped, "ID", "%d", d.ID)
+	}
+	if d.ZoomLevel != 0 {
+		r.Add(divemodel.ReportKind_Dropped, "ZoomLevel", "%d", d.ZoomLevel)
+	}
+	for _, a := range d.Extra.Attr {
+		r.Add(divemodel.ReportKind_Dropped, "Extra", "attribute %s=%q", a.Name.Local, a.Value)
+	}
+	for _, e := range d.Extra.Elements {
+		r.Add(divemodel.ReportKind_Dropped, "Extra", "unknown %v", e)
+	}
+
+	return m, r
+}
+
+// FromModel converts the format-neutral dive model to Data. The returned report
+// lists the fields that could not be converted and the assumptions made.
+//
+// Only the first cylinder is exported. If the water temperature at the
+// maximum depth is not known, the minimum water temperature is used instead.
+// The dive computer is added to the log notes if its model is known. A device
+// ID alone is not added, so that the user's notes are not cluttered with
+// "unknown dive computer" lines.
+func FromModel(m *divemodel.Dive) (Data, divemodel.Report) {
+	var r divemodel.Report
+	drop := func(set bool, field, format string, args ...interface{}) {
+		if set {
+			r.Add(divemodel.ReportKind_Dropped, field, format, args...)
+		}
+	}
+
+	d := Data{
+		DiveNumber:          m.Number,
+		Time:                m.Time,
+		DiveDuration:        m.Duration,
+		SurfaceDuration:     m.SurfaceInterval,
+		MaxDepth:            m.MaxDepth,
+		MeanDepth:           m.MeanDepth,
+		Location:            m.Location.Name,
+		Site:                m.Location.Site,
+		Weather:             m.Conditions.Weather,
+		Visibility:          m.Conditions.Visibility,
+		AirTemperature:      m.Temperatures.Air,
+		MaxDepthTemperature: m.Temperatures.MaxDepth,
+		DiveEndTemperature:  m.Temperatures.End,
+		Partner:             m.Partner,
+		Boat:                m.Boat,
+		Weight:              m.Weight,
+		LogNotes:            m.Notes,
+		Latitude:            m.Location.Latitude,
+		Longitude:           m.Location.Longitude,
+		SampleInterval:      m.SampleInterval,
+	}
+
+	if d.MaxDepthTemperature == 0 && m.Temperatures.WaterMin != 0 {
+		d.MaxDepthTemperature = m.Temperatures.WaterMin
+		r.Add(divemodel.ReportKind_Heuristic, "Temperatures.WaterMin",
+			"assumed to be the water temperature at the maximum depth")
+	} else {
+		drop(m.Temperatures.WaterMin != 0, "Temperatures.WaterMin", "%.1f °C", m.Temperatures.WaterMin)
+	}
+	drop(m.Temperatures.WaterMax != 0, "Temperatures.WaterMax", "%.1f °C", m.Temperatures.WaterMax)
+	drop(m.Density != 0, "Density", "%g g/l", m.Density)
+
+	if len(m.Cylinders) > 0 {
+		c := m.Cylinders[0]
+		d.Cylinder = Cylinder{
+			Name:            c.Name,
+			Description:     c.Description,
+			Doubles:         c.Doubles,
+			Size:            c.Size,
+			StartPressure:   c.StartPressure,
+			EndPressure:     c.EndPressure,
+			WorkingPressure: c.WorkingPressure,
+		}
+		d.O2Percent = c.Gas.PercentO2
+		d.HEPercent = c.Gas.PercentHe
+		drop(c.Gas.MaxPO2 != 0, "Cylinders[0].Gas.MaxPO2", "%.2f bar", c.Gas.MaxPO2)
+	}
+	for i, c := range m.Cylinders {
+		if i > 0 {
+			drop(true, fmt.Sprintf("Cylinders[%d]", i), "%+v", c)
+		}
+	}
+	for _, gc := range m.GasChanges {
+		drop(true, "GasChanges", "switch to cylinder %d at %v", gc.Cylinder, gc.Time)
+	}
+	for _, e := range m.Events {
+		drop(true, "Events", "%v at %v", e.Type, e.Time)
+	}
+
+	switch {
+	case m.Computer.Model != "":
+		if d.LogNotes != "" {
+			d.LogNotes += "\n"
+		}
+		d.LogNotes += "Dive computer: " + m.Computer.String()
+		r.Add(divemodel.ReportKind_Heuristic, "Computer", "added to LogNotes")
+	case m.Computer != (divemodel.Computer{}):
+		drop(true, "Computer", "%v", m.Computer)
+	}
+
+	var temperature, pressure, heartRate, noStop bool
+	for _, s := range m.Samples {
+		d.Samples = append(d.Samples, Sample{
+			Depth: s.Depth,
+		})
+		temperature = temperature || s.Temperature != 0
+		pressure = pressure || s.Pressure != 0
+		heartRate = heartRate || s.HeartRate != 0
+		noStop = noStop || s.NoStopTime != 0
+	}
+	drop(temperature, "Samples.Temperature", "temperature samples")
+	drop(pressure, "Samples.Pressure", "tank pressure samples")
+	drop(heartRate, "Samples.HeartRate", "heart rate samples")
+	drop(noStop, "Samples.NoStopTime", "no-stop time samples")
+
+	if d.SampleInterval == 0 && len(d.Samples) > 1 {
+		r.Add(divemodel.ReportKind_Defaulted, "SampleInterval", "unknown, the samples have no time")
+	}
+
+	return d, r
+}
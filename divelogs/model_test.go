@@ -0,0 +1,98 @@
+package divelogs
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/octo/divelogs-go/divemodel"
+)
+
+func TestModel(t *testing.T) {
+	testdata, err := ioutil.ReadFile("testdata/data.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want Data
+	if err := xml.Unmarshal(testdata, &want); err != nil {
+		t.Fatal(err)
+	}
+
+	m, report := ToModel(want)
+	wantReport := divemodel.Report{
+		{Kind: divemodel.ReportKind_Dropped, Field: "ID", Detail: "3355222"},
+		{Kind: divemodel.ReportKind_Dropped, Field: "ZoomLevel", Detail: "12"},
+	}
+	if diff := cmp.Diff(wantReport, report); diff != "" {
+		t.Errorf("ToModel() report differs (-want/+got):\n%s", diff)
+	}
+
+	got, fromReport := FromModel(m)
+	if len(fromReport) != 0 {
+		t.Errorf("FromModel() report = %v, want empty", fromReport)
+	}
+
+	// The divelogs.de ID and the map zoom level are not part of the model.
+	want.ID = 0
+	want.ZoomLevel = 0
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("FromModel(ToModel()) differs (-want/+got):\n%s", diff)
+	}
+
+	m = &divemodel.Dive{
+		Temperatures: divemodel.Temperatures{WaterMin: 8.8},
+		Computer:     divemodel.Computer{Model: "Galileo Sol"},
+	}
+	d, report := FromModel(m)
+	if got, want := d.MaxDepthTemperature, 8.8; got != want {
+		t.Errorf("MaxDepthTemperature = %g, want %g", got, want)
+	}
+	if got, want := d.LogNotes, "Dive computer: Galileo Sol"; got != want {
+		t.Errorf("LogNotes = %q, want %q", got, want)
+	}
+	wantReport = divemodel.Report{
+		{Kind: divemodel.ReportKind_Heuristic, Field: "Temperatures.WaterMin", Detail: "assumed to be the water temperature at the maximum depth"},
+		{Kind: divemodel.ReportKind_Heuristic, Field: "Computer", Detail: "added to LogNotes"},
+	}
+	if diff := cmp.Diff(wantReport, report); diff != "" {
+		t.Errorf("FromModel() report differs (-want/+got):\n%s", diff)
+	}
+
+	// An unregistered dive computer is not added to the notes.
+	m = &divemodel.Dive{
+		Notes:    "Nice dive",
+		Computer: divemodel.Computer{ID: "0x00c0ffee"},
+	}
+	d, report = FromModel(m)
+	if got, want := d.LogNotes, "Nice dive"; got != want {
+		t.Errorf("LogNotes = %q, want %q", got, want)
+	}
+	wantReport = divemodel.Report{
+		{Kind: divemodel.ReportKind_Dropped, Field: "Computer", Detail: "unknown dive computer (ID 0x00c0ffee)"},
+	}
+	if diff := cmp.Diff(wantReport, report); diff != "" {
+		t.Errorf("FromModel() report differs (-want/+got):\n%s", diff)
+	}
+}
+
+func TestToModel_Extra(t *testing.T) {
+	var d Data
+	if err := xml.Unmarshal([]byte(extraXML), &d); err != nil {
+		t.Fatal(err)
+	}
+
+	_, report := ToModel(d)
+	want := divemodel.Report{
+		{Kind: divemodel.ReportKind_Dropped, Field: "ID", Detail: "1"},
+		{Kind: divemodel.ReportKind_Dropped, Field: "Extra", Detail: `attribute version="2"`},
+		{Kind: divemodel.ReportKind_Dropped, Field: "Extra", Detail: "unknown element <RATING>"},
+		{Kind: divemodel.ReportKind_Dropped, Field: "Extra", Detail: "unknown element <DIVETYPE>"},
+		{Kind: divemodel.ReportKind_Dropped, Field: "Extra", Detail: "unknown element <CYLINDER>"},
+		{Kind: divemodel.ReportKind_Dropped, Field: "Extra", Detail: "unknown element <NEWFIELD>"},
+	}
+	if diff := cmp.Diff(want, report); diff != "" {
+		t.Errorf("ToModel() report differs (-want/+got):\n%s", diff)
+	}
+}
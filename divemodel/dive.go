@@ -0,0 +1,164 @@
+// Package divemodel implements a format-neutral description of a dive.
+//
+// Each file format provides conversions to and from Dive, so that converting
+// between two formats only requires one mapping per format.
+package divemodel
+
+import (
+	"fmt"
+	"time"
+)
+
+// Dive is a single dive.
+//
+// Zero values denote fields that are not known. Durations within the dive,
+// such as the time of a sample, are relative to the start of the dive.
+type Dive struct {
+	// Number is the dive number in the diver's logbook or in the memory
+	// of the dive computer.
+	Number          int
+	Time            time.Time
+	Duration        time.Duration
+	SurfaceInterval time.Duration
+	MaxDepth        float64
+	MeanDepth       float64
+	// Density is the density of the water in grams per Liter, e.g. 1000
+	// for fresh water and 1025 for salt water.
+	Density      float64
+	Temperatures Temperatures
+	Location     Location
+	Conditions   Conditions
+	Partner      string
+	Boat         string
+	Weight       float64
+	Notes        string
+	Computer     Computer
+	// Cylinders are the tanks carried. The first cylinder is the one used
+	// at the start of the dive.
+	Cylinders []Cylinder
+	// GasChanges lists the switches to a different cylinder during the
+	// dive.
+	GasChanges     []GasChange
+	SampleInterval time.Duration
+	Samples        []Sample
+	Events         []Event
+}
+
+// Temperatures holds the temperatures in degrees Celsius.
+type Temperatures struct {
+	Air float64
+	// WaterMin and WaterMax are the lowest and highest water temperature
+	// during the dive.
+	WaterMin float64
+	WaterMax float64
+	// MaxDepth is the water temperature at the maximum depth.
+	MaxDepth float64
+	// End is the water temperature at the end of the dive.
+	End float64
+}
+
+// Location describes where the dive took place.
+type Location struct {
+	// Name is the name of the area, e.g. a lake, and Site is the name of
+	// the dive site within it.
+	Name      string
+	Site      string
+	Latitude  float64
+	Longitude float64
+}
+
+// Conditions describes the environment during the dive.
+type Conditions struct {
+	Weather    string
+	Visibility string
+}
+
+// Computer describes the dive computer that recorded the dive.
+type Computer struct {
+	Model string
+	// ID identifies the dive computer in the source format, e.g. the
+	// device ID of SmartTrak. It is not necessarily the serial number.
+	ID       string
+	Firmware string
+}
+
+func (c Computer) String() string {
+	model := c.Model
+	if model == "" {
+		model = "unknown dive computer"
+	}
+	if c.ID != "" {
+		return fmt.Sprintf("%s (ID %s)", model, c.ID)
+	}
+	return model
+}
+
+// Cylinder is a tank and the gas it contains. Pressures are in bar, the size
+// is in Liters.
+type Cylinder struct {
+	Name            string
+	Description     string
+	Doubles         bool
+	Size            float64
+	WorkingPressure float64
+	StartPressure   float64
+	EndPressure     float64
+	Gas             Gas
+}
+
+// Gas is a breathing gas. The remainder is nitrogen.
+type Gas struct {
+	PercentO2 float64
+	PercentHe float64
+	// MaxPO2 is the maximum partial pressure of oxygen in bar.
+	MaxPO2 float64
+}
+
+// GasChange is a switch to Cylinders[Cylinder] at Time.
+type GasChange struct {
+	Time     time.Duration
+	Cylinder int
+}
+
+// Sample is a single data point of the dive profile.
+type Sample struct {
+	Time        time.Duration
+	Depth       float64
+	Temperature float64
+	// Pressure is the tank pressure in bar.
+	Pressure float64
+	// HeartRate is in beats per minute.
+	HeartRate int
+	// NoStopTime is the remaining no-decompression time.
+	NoStopTime time.Duration
+}
+
+// EventType is the kind of an Event.
+type EventType int
+
+const (
+	EventType_Bookmark EventType = iota + 1
+	EventType_Warning
+	EventType_Alert
+	EventType_HighWorkload
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventType_Bookmark:
+		return "bookmark"
+	case EventType_Warning:
+		return "warning"
+	case EventType_Alert:
+		return "alert"
+	case EventType_HighWorkload:
+		return "high workload"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event is something that happened during the dive.
+type Event struct {
+	Time time.Duration
+	Type EventType
+}
@@ -0,0 +1,62 @@
+package divemodel
+
+import "fmt"
+
+// ReportKind classifies the entries of a Report.
+type ReportKind int
+
+const (
+	// ReportKind_Dropped means that a value could not be represented in
+	// the target format and was discarded.
+	ReportKind_Dropped ReportKind = iota + 1
+	// ReportKind_Heuristic means that a value was mapped based on an
+	// assumption, and may be wrong.
+	ReportKind_Heuristic
+	// ReportKind_Defaulted means that the source did not provide a value
+	// and a default was used.
+	ReportKind_Defaulted
+)
+
+func (k ReportKind) String() string {
+	switch k {
+	case ReportKind_Dropped:
+		return "dropped"
+	case ReportKind_Heuristic:
+		return "heuristic"
+	case ReportKind_Defaulted:
+		return "defaulted"
+	}
+	return fmt.Sprintf("ReportKind(%d)", int(k))
+}
+
+// ReportEntry describes a single field that did not survive a conversion
+// unchanged.
+type ReportEntry struct {
+	Kind ReportKind
+	// Field is the name of the field in the source format for dropped and
+	// heuristically mapped values, and in the target format for defaulted
+	// values.
+	Field  string
+	Detail string
+}
+
+func (e ReportEntry) String() string {
+	return fmt.Sprintf("%v: %s: %s", e.Kind, e.Field, e.Detail)
+}
+
+// Report lists everything that was lost or guessed while converting a dive.
+// Dropped fields are only reported if they hold a value.
+//
+// The reports of the conversion to and from Dive can be combined with
+// append.
+type Report []ReportEntry
+
+// Add appends an entry to the report. The detail is formatted using
+// fmt.Sprintf.
+func (r *Report) Add(kind ReportKind, field, format string, args ...interface{}) {
+	*r = append(*r, ReportEntry{
+		Kind:   kind,
+		Field:  field,
+		Detail: fmt.Sprintf(format, args...),
+	})
+}
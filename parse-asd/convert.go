@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/octo/divelogs-go/divelogs"
+	"github.com/octo/divelogs-go/smarttrak"
+)
+
+// runConvert writes the selected dives as divelogs.de XML. A single dive is
+// written as <DIVELOGSDATA>, multiple dives as a <DIVELOGSLOGBOOK>, formatted
+// like the exports of divelogs.de. Dates and times are written in the time
+// zone selected with -tz. The conversion reports are printed to standard
+// error.
+func runConvert(w io.Writer, in *input) error {
+	var dl divelogs.Logbook
+	for _, i := range in.dives {
+		m, report := smarttrak.ToModel(in.lb.Dives[i])
+		d, r := divelogs.FromModel(m)
+		for _, e := range append(report, r...) {
+			fmt.Fprintf(os.Stderr, "dive %d: %v\n", i+1, e)
+		}
+		dl.Dives = append(dl.Dives, d)
+	}
+
+	var v interface{} = dl
+	if len(dl.Dives) == 1 {
+		v = dl.Dives[0]
+	}
+
+	enc := divelogs.NewEncoder(w)
+	enc.Location = in.location
+	enc.Format = divelogs.Format_Divelogs
+	return enc.Encode(v)
+}
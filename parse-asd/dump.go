@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/octo/divelogs-go/smarttrak"
+)
+
+// bytesPerLine is the number of bytes printed per line by runDump.
+const bytesPerLine = 16
+
+// runDump prints a hex dump of the file, annotated with the name, the decoded
+// value and the status of each field. If a dive has been selected with -dive,
+// only the fields of that dive are printed. Files that cannot be read are
+// dumped, too: the part that could not be parsed is printed as "Unparsed".
+func runDump(w io.Writer, in *input) error {
+	fields := smarttrak.Annotate(in.data)
+
+	selected := make(map[int]bool)
+	for _, i := range in.dives {
+		selected[i+1] = true
+	}
+	all := len(in.dives) == 0
+	if in.lb != nil {
+		all = len(in.dives) == len(in.lb.Dives)
+	}
+
+	for _, f := range fields {
+		if !all && (f.Dive == 0 || !selected[f.Dive]) {
+			continue
+		}
+
+		section := "header"
+		if f.Dive != 0 {
+			section = fmt.Sprintf("dive %d", f.Dive)
+		}
+		desc := f.Name
+		if f.Value != "" {
+			desc += " = " + f.Value
+		}
+
+		data := in.data[f.Offset : f.Offset+f.Size]
+		for off := 0; off < len(data); off += bytesPerLine {
+			end := off + bytesPerLine
+			if end > len(data) {
+				end = len(data)
+			}
+			hex := fmt.Sprintf("% x", data[off:end])
+			if off == 0 {
+				fmt.Fprintf(w, "%08x  %-8s %-9s  %-47s  %s\n", f.Offset, section, f.Status, hex, desc)
+			} else {
+				fmt.Fprintf(w, "%08x  %-8s %-9s  %s\n", f.Offset+off, "", "", hex)
+			}
+		}
+	}
+	return nil
+}
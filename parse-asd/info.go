@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/octo/divelogs-go/divelogs"
+	"github.com/octo/divelogs-go/smarttrak"
+)
+
+// runInfo prints the header and the details of the selected dives.
+func runInfo(w io.Writer, in *input) error {
+	lb := in.lb
+	fmt.Fprintf(w, "File version: %d\n", lb.Header.Version)
+	fmt.Fprintf(w, "Logbook name: %q\n", lb.Header.Name)
+	fmt.Fprintf(w, "Suit type:    %q\n", lb.Header.SuitType)
+	fmt.Fprintf(w, "Weather:      %q\n", lb.Header.Weather)
+	fmt.Fprintf(w, "Dives:        %d\n", len(lb.Dives))
+
+	for _, i := range in.dives {
+		fmt.Fprintf(w, "\n# Dive %d\n", i+1)
+		printDive(w, lb.Dives[i])
+	}
+	return nil
+}
+
+func printDive(w io.Writer, dv *smarttrak.Dive) {
+	fmt.Fprintf(w, "DeviceID:    %#08x\n", dv.DeviceID)
+	fmt.Fprintf(w, "Computer:    %v\n", dv.Device)
+	fmt.Fprintf(w, "Pressure:    %v\n", dv.PressureStream())
+	fmt.Fprintf(w, "Heart rate:  %v\n", dv.HeartRateStream())
+	fmt.Fprintf(w, "Time:        %v\n", dv.Time)
+	fmt.Fprintf(w, "Sequence:    %d\n", dv.Sequence)
+	fmt.Fprintf(w, "Air temp:    %.1f\n", dv.AirTemperature)
+	fmt.Fprintf(w, "Time limit:  %v\n", dv.TimeLimit)
+	fmt.Fprintf(w, "Max depth:   %.1f\n", dv.MaxDepth)
+	fmt.Fprintf(w, "Duration:    %v\n", dv.Duration)
+	fmt.Fprintf(w, "Interval:    %v\n", dv.SampleInterval)
+	fmt.Fprintf(w, "Min temp:    %.1f\n", dv.MinTemperature)
+	fmt.Fprintf(w, "Surface Int: %v\n", dv.SurfaceInterval)
+	fmt.Fprintf(w, "Pres. Start: %.1f\n", dv.PressureStart)
+	fmt.Fprintf(w, "Pres. End:   %.1f\n", dv.PressureEnd)
+	fmt.Fprintf(w, "Settings:    %v\n", dv.Settings.Known())
+	fmt.Fprintf(w, "Unknown:     %v\n", dv.Settings.UnknownBits())
+	fmt.Fprintf(w, "ppO2 limit:  %.2f\n", dv.PPO2Limit)
+	fmt.Fprintf(w, "Work sens.:  %d\n", dv.WorkSensitivity)
+	fmt.Fprintf(w, "Trailer:     % x\n", dv.Trailer)
+
+	fmt.Fprintf(w, "Main info: Date: %s; Sequence: %d; Duration: %s;\n",
+		dv.Time, dv.Sequence, dv.Duration)
+	fmt.Fprintf(w, "Temperatures: Min: %.1f; Max: %.1f; Deco: %.1f; Air: %.1f;\n",
+		dv.MinTemperature, dv.MaxTemperature, dv.DecoTemperature, dv.AirTemperature)
+	fmt.Fprintf(w, "Depths: Average: %.1f; Max: %.1f;\n",
+		dv.AverageDepth, dv.MaxDepth)
+
+	if err := dv.CheckProfile(smarttrak.DefaultDepthTolerance); err != nil {
+		fmt.Fprintf(w, "Warning: %v\n", err)
+	}
+
+	if len(dv.Profile) > 0 {
+		fmt.Fprintf(w, "Calculated start temp: %.1f (confidence: %v)\n", dv.Profile[0].Temperature, dv.TemperatureConfidence)
+	}
+
+	for _, mix := range dv.GasMixes {
+		fmt.Fprintf(w, "Gas mix:     %d%% O₂, %d%% He, max pO₂ %.2f, from %v\n",
+			mix.PercentO2, mix.PercentHE, mix.MaxPO2, mix.Time)
+	}
+
+	// No-stop times are only valid after the first no-stop block.
+	for _, ev := range dv.Events {
+		if ev.Type != smarttrak.BlockType_Extended || ev.ExtendedType != smarttrak.ExtendedType_NoStop {
+			continue
+		}
+		minNoStop := time.Duration(math.MaxInt64)
+		for _, p := range dv.Profile[ev.Index:] {
+			if p.NoStopTime < minNoStop {
+				minNoStop = p.NoStopTime
+			}
+		}
+		if ev.Index < len(dv.Profile) {
+			fmt.Fprintf(w, "Min. no-stop time: %v\n", minNoStop)
+		}
+		break
+	}
+
+	for _, ev := range dv.Events {
+		fmt.Fprintf(w, "Event: %v\n", ev)
+	}
+
+	m, report := smarttrak.ToModel(dv)
+	_, r := divelogs.FromModel(m)
+	for _, e := range append(report, r...) {
+		fmt.Fprintf(w, "Conversion to divelogs.de: %v\n", e)
+	}
+}
@@ -1,58 +1,207 @@
+// Command parse-asd reads SmartTrak .asd files.
+//
+// Usage:
+//
+//	parse-asd <command> [flags] [file]
+//
+// The commands are:
+//
+//	info     print the header and the details of each dive
+//	convert  convert the dives to divelogs.de XML
+//	profile  print the profile data of each dive
+//	dump     print an annotated hex dump of the file
+//
+// The .asd file is read from standard input if no file, or "-", is given.
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"os"
+	"time"
 
+	"github.com/octo/divelogs-go/divelogs"
 	"github.com/octo/divelogs-go/smarttrak"
 )
 
-var flagInput = flag.String("input", "", "path to input file")
+// input is the data passed to the commands.
+type input struct {
+	data []byte
+	// lb is nil if the file could not be read, see err.
+	lb *smarttrak.Logbook
+	// err is the error reading the file. Only the dump command accepts
+	// files that cannot be read.
+	err error
+	// dives holds the indexes of the dives selected with -dive.
+	dives []int
+	// location is the time zone selected with -tz. It is nil if the dives
+	// are written in the device's time zone.
+	location *time.Location
+}
+
+var commands = map[string]func(w io.Writer, in *input) error{
+	"info":    runInfo,
+	"convert": runConvert,
+	"profile": runProfile,
+	"dump":    runDump,
+}
+
+func usage(fs *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintf(fs.Output(), "usage: parse-asd <info|convert|profile|dump> [flags] [file]\n")
+		fs.PrintDefaults()
+	}
+}
 
 func main() {
-	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("parse-asd: ")
+
+	fs := flag.NewFlagSet("parse-asd", flag.ExitOnError)
+	fs.Usage = usage(fs)
+	var (
+		flagInput  = fs.String("input", "", "path to input file (deprecated, pass the file as an argument)")
+		flagOutput = fs.String("output", "-", `path to output file, or "-" for standard output`)
+		flagDive   = fs.Int("dive", 0, "number of the dive to process, starting at 1; zero processes all dives")
+		flagTZ     = fs.String("tz", "", `time zone of the converted dives, e.g. "UTC", "Europe/Berlin" or "+02:00"; empty uses the device's offset`)
+		registry   smarttrak.Registry
+	)
+	fs.Var(&registry, "devices", `comma separated list of dive computers and, optionally, their sample interval, e.g. "0x00C0FFEE=Galileo Sol/4s"`)
+
+	// "parse-asd -input file" predates the commands and prints the info.
+	args := os.Args[1:]
+	name := "info"
+	if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+		name, args = args[0], args[1:]
+	}
+	run, ok := commands[name]
+	if !ok {
+		fs.Usage()
+		os.Exit(2)
+	}
+	fs.Parse(args)
 
-	if *flagInput == "" {
-		flag.Usage()
+	input := *flagInput
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		input = fs.Arg(0)
+	default:
+		fs.Usage()
+		os.Exit(2)
 	}
 
-	data, err := ioutil.ReadFile(*flagInput)
+	in, err := readInput(input, *flagDive, &registry)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if in.err != nil {
+		if name != "dump" {
+			log.Fatal(in.err)
+		}
+		log.Printf("%v; dumping the file anyway", in.err)
+	}
+	if in.location, err = divelogs.ParseLocation(*flagTZ); err != nil {
+		log.Fatal(err)
+	}
 
-	r := bytes.NewReader(data)
-	hdr, err := smarttrak.ReadHeader(r)
-	if err != nil {
+	if err := writeOutput(*flagOutput, func(w io.Writer) error {
+		return run(w, in)
+	}); err != nil {
 		log.Fatal(err)
 	}
-	fmt.Printf("Logbook name: %q\n", hdr.Name)
+}
 
-	dv, err := smarttrak.ReadDive(r)
+// readInput reads the .asd file at path, or from standard input if path is
+// empty or "-", and selects the dive with the given number. The dive
+// computers are looked up in registry. If the file cannot be parsed, the
+// error is returned in input.err, and the dive is selected without checking
+// that it exists.
+func readInput(path string, dive int, registry *smarttrak.Registry) (*input, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "" || path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+
+	rd := smarttrak.NewReader(bytes.NewReader(data))
+	rd.Registry = registry
+	lb, err := rd.ReadLogbook()
+	if err != nil {
+		in := &input{
+			data: data,
+			err:  err,
+		}
+		if dive != 0 {
+			in.dives = []int{dive - 1}
+		}
+		return in, nil
+	}
+
+	dives, err := selectDives(lb, dive)
+	if err != nil {
+		return nil, err
+	}
+
+	return &input{
+		data:  data,
+		lb:    lb,
+		dives: dives,
+	}, nil
+}
+
+// selectDives returns the indexes of the dives to process. n is the number of
+// the dive, starting at 1, or zero for all dives.
+func selectDives(lb *smarttrak.Logbook, n int) ([]int, error) {
+	if n < 0 || n > len(lb.Dives) {
+		return nil, fmt.Errorf("dive %d does not exist, the file contains %d dives", n, len(lb.Dives))
+	}
+	if n != 0 {
+		return []int{n - 1}, nil
+	}
+
+	dives := make([]int, len(lb.Dives))
+	for i := range dives {
+		dives[i] = i
+	}
+	return dives, nil
+}
+
+// writeOutput calls write with the file at path, or with standard output if
+// path is "-".
+func writeOutput(path string, write func(w io.Writer) error) error {
+	if path == "-" {
+		w := bufio.NewWriter(os.Stdout)
+		if err := write(w); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
 	}
-	fmt.Printf("DeviceID:    %#08x\n", dv.DeviceID)
-	fmt.Printf("Time:        %v\n", dv.Time)
-	fmt.Printf("Sequence:    %d\n", dv.Sequence)
-	fmt.Printf("Air temp:    %.1f\n", dv.AirTemperature)
-	fmt.Printf("Time limit:  %v\n", dv.TimeLimit)
-	fmt.Printf("Max depth:   %.1f\n", dv.MaxDepth)
-	fmt.Printf("Duration:    %v\n", dv.Duration)
-	fmt.Printf("Min temp:    %.1f\n", dv.MinTemperature)
-	fmt.Printf("Surface Int: %v\n", dv.SurfaceInterval)
-	fmt.Printf("Pres. Start: %.1f\n", dv.PressureStart)
-	fmt.Printf("Pres. End:   %.1f\n", dv.PressureEnd)
-
-	fmt.Printf("Main info: Date: %s; Sequence: %d; Duration: %s;\n",
-		dv.Time, dv.Sequence, dv.Duration)
-	fmt.Printf("Temperatures: Min: %.1f; Max: %.1f; Deco: %.1f; Air: %.1f;\n",
-		dv.MinTemperature, dv.MaxTemperature, dv.DecoTemperature, dv.AirTemperature)
-	fmt.Printf("Depths: Average: %.1f; Max: %.1f;\n",
-		dv.AverageDepth, dv.MaxDepth)
-
-	fmt.Printf("Calculated start temp: %.1f\n", dv.Profile[0].Temperature)
+	return f.Close()
 }
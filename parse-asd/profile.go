@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// runProfile prints the profile data of the selected dives, one data point
+// per line.
+func runProfile(w io.Writer, in *input) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "dive\ttime\tdepth\ttemp\tno-stop\tmb no-stop\tstate\t")
+
+	for _, i := range in.dives {
+		dv := in.lb.Dives[i]
+		for _, p := range dv.Profile {
+			fmt.Fprintf(tw, "%d\t%v\t%.2f\t%.1f\t%v\t%v\t%s\t\n",
+				i+1, p.Time.Sub(dv.Time), p.Depth, p.Temperature, p.NoStopTime, p.MBNoStopTime, p.State())
+		}
+	}
+
+	return tw.Flush()
+}
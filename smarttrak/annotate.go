@@ -0,0 +1,304 @@
+package smarttrak
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// FieldStatus describes how well a field of the file format is understood.
+type FieldStatus int
+
+const (
+	// FieldStatus_Known fields are decoded and their meaning is known.
+	FieldStatus_Known FieldStatus = iota
+	// FieldStatus_Uncertain fields are decoded, but their meaning or their
+	// encoding is a guess, or only partially known.
+	FieldStatus_Uncertain
+	// FieldStatus_Unknown fields are not understood at all.
+	FieldStatus_Unknown
+)
+
+func (s FieldStatus) String() string {
+	switch s {
+	case FieldStatus_Known:
+		return "known"
+	case FieldStatus_Uncertain:
+		return "uncertain"
+	case FieldStatus_Unknown:
+		return "unknown"
+	}
+	return fmt.Sprintf("FieldStatus(%d)", int(s))
+}
+
+// Field is a range of bytes in an .asd file.
+type Field struct {
+	// Dive is the number of the dive the field belongs to, starting at 1.
+	// It is zero for fields of the file header.
+	Dive   int
+	Offset int
+	Size   int
+	Name   string
+	Status FieldStatus
+	// Value is the decoded value. It is empty if the bytes are not decoded.
+	Value string
+}
+
+// recordField describes a field of the fixed size dive record.
+type recordField struct {
+	offset, size int
+	name         string
+	status       FieldStatus
+	value        func(d *Dive) interface{}
+}
+
+// recordFields lists the fields of the dive record that have been
+// identified, ordered by offset. The bytes between them are unknown.
+var recordFields = []recordField{
+	{8, 4, "DeviceID", FieldStatus_Known, func(d *Dive) interface{} { return fmt.Sprintf("%#08x", d.DeviceID) }},
+	{16, 8, "Time", FieldStatus_Known, func(d *Dive) interface{} { return d.Time.UTC() }},
+	{24, 2, "Time zone", FieldStatus_Known, func(d *Dive) interface{} { return d.Time.Format("-07:00") }},
+	{28, 2, "Sequence", FieldStatus_Known, func(d *Dive) interface{} { return d.Sequence }},
+	{30, 2, "AirTemperature", FieldStatus_Known, func(d *Dive) interface{} { return d.AirTemperature }},
+	{33, 2, "TimeLimit", FieldStatus_Known, func(d *Dive) interface{} { return d.TimeLimit }},
+	{35, 4, "Settings.FeatureSet", FieldStatus_Uncertain, func(d *Dive) interface{} { return fmt.Sprintf("%#08x", d.Settings.FeatureSet) }},
+	{42, 2, "MaxDepth", FieldStatus_Known, func(d *Dive) interface{} { return d.MaxDepth }},
+	{44, 2, "Duration", FieldStatus_Known, func(d *Dive) interface{} { return d.Duration }},
+	{46, 2, "MinTemperature", FieldStatus_Known, func(d *Dive) interface{} { return d.MinTemperature }},
+	{50, 2, "SurfaceInterval", FieldStatus_Known, func(d *Dive) interface{} { return d.SurfaceInterval }},
+	{54, 2, "PressureStart", FieldStatus_Known, func(d *Dive) interface{} { return d.PressureStart }},
+	{56, 2, "PressureEnd", FieldStatus_Known, func(d *Dive) interface{} { return d.PressureEnd }},
+	{60, 2, "PPO2Limit", FieldStatus_Uncertain, func(d *Dive) interface{} { return d.PPO2Limit }},
+	{62, 2, "DepthLimit", FieldStatus_Known, func(d *Dive) interface{} { return d.DepthLimit }},
+	{64, 2, "TankWarning", FieldStatus_Known, func(d *Dive) interface{} { return d.TankWarning }},
+	{66, 2, "TankReserve", FieldStatus_Known, func(d *Dive) interface{} { return d.TankReserve }},
+	{68, 2, "WorkSensitivity", FieldStatus_Uncertain, func(d *Dive) interface{} { return d.WorkSensitivity }},
+	{70, 2, "DecoTemperature", FieldStatus_Known, func(d *Dive) interface{} { return d.DecoTemperature }},
+	{72, 2, "DesatBefore", FieldStatus_Uncertain, func(d *Dive) interface{} { return d.DesatBefore }},
+	{82, 4, "Settings.Settings1", FieldStatus_Uncertain, func(d *Dive) interface{} { return fmt.Sprintf("%#08x %v", d.Settings.Settings1, d.Settings.Known()) }},
+	{158, 2, "AverageDepth", FieldStatus_Known, func(d *Dive) interface{} { return d.AverageDepth }},
+	{160, 2, "MaxTemperature", FieldStatus_Known, func(d *Dive) interface{} { return d.MaxTemperature }},
+	{167, 4, "Settings.Settings2", FieldStatus_Uncertain, func(d *Dive) interface{} { return fmt.Sprintf("%#08x", d.Settings.Settings2) }},
+	{191, 2, "timeseries size", FieldStatus_Known, func(d *Dive) interface{} { return d.timeseriesSize }},
+}
+
+// RecordFields returns the fields of the fixed size dive record that have been
+// identified. Offsets are relative to the start of the record; Dive and Value
+// are not set.
+func RecordFields() []Field {
+	var ret []Field
+	for _, f := range recordFields {
+		ret = append(ret, Field{
+			Offset: f.offset,
+			Size:   f.size,
+			Name:   f.name,
+			Status: f.status,
+		})
+	}
+	return ret
+}
+
+// Annotate describes every byte of the .asd file in data. The returned fields
+// are ordered by offset and cover the entire file. Annotate is intended to
+// help with reverse engineering the file format, so it also annotates files
+// that cannot be read: the parts that could be parsed are annotated, and the
+// rest of the file is covered by a field named "Unparsed", whose Value is the
+// parse error.
+func Annotate(data []byte) []Field {
+	a := &annotator{
+		data: data,
+	}
+	r := &reader{r: bytes.NewReader(data)}
+
+	h, l, err := readHeader(r)
+	if err != nil {
+		a.unparsed(err)
+		return a.fields
+	}
+	a.header(h)
+
+	for a.dive = 1; ; a.dive++ {
+		start := r.off
+		d, err := readDive(r, l)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			a.partialDive(start, l)
+			a.unparsed(err)
+			return a.fields
+		}
+		a.record(d)
+		a.timeseries(d.timeseries)
+		a.add(l.trailerSize, "Trailer", FieldStatus_Unknown, nil)
+	}
+
+	return a.fields
+}
+
+// annotator appends fields for consecutive byte ranges of the file.
+type annotator struct {
+	data   []byte
+	off    int
+	dive   int
+	fields []Field
+}
+
+// add appends a field of the given size at the current offset.
+func (a *annotator) add(size int, name string, status FieldStatus, value interface{}) {
+	f := Field{
+		Dive:   a.dive,
+		Offset: a.off,
+		Size:   size,
+		Name:   name,
+		Status: status,
+	}
+	if value != nil {
+		f.Value = fmt.Sprint(value)
+	}
+	a.fields = append(a.fields, f)
+	a.off += size
+}
+
+// unparsed annotates the rest of the file, which could not be parsed because
+// of err.
+func (a *annotator) unparsed(err error) {
+	if n := len(a.data) - a.off; n > 0 {
+		a.add(n, "Unparsed", FieldStatus_Unknown, err)
+	}
+}
+
+// partialDive annotates what can be parsed of the dive at offset start, which
+// could not be read: the record if it is complete, and the blocks of the
+// timeseries data up to the end of the file or the first invalid block.
+func (a *annotator) partialDive(start int, l layout) {
+	if start+l.recordSize > len(a.data) {
+		return
+	}
+	d, err := parseRecord(a.data[start:start+l.recordSize], l)
+	if err != nil {
+		return
+	}
+	a.record(d)
+
+	end := a.off + int(d.timeseriesSize)
+	if end > len(a.data) {
+		end = len(a.data)
+	}
+	a.timeseries(a.data[a.off:end])
+}
+
+func (a *annotator) header(h *Header) {
+	a.add(2, "Version", FieldStatus_Uncertain, h.Version)
+	a.add(2, "ClassName length", FieldStatus_Known, len(h.ClassName))
+	a.add(len(h.ClassName), "ClassName", FieldStatus_Known, h.ClassName)
+
+	for _, f := range []struct {
+		name, value string
+		unknown     []byte
+	}{
+		{"Name", h.Name, h.Unknown1},
+		{"SuitType", h.SuitType, h.Unknown2},
+		{"Weather", h.Weather, h.Unknown3},
+	} {
+		// The size of the string depends on its encoding, so it is
+		// determined by reading it again.
+		// readHeader has read the string before, so this does not fail.
+		r := &reader{
+			r:   bytes.NewReader(a.data[a.off:]),
+			off: a.off,
+		}
+		r.readString()
+		a.add(r.off-a.off, f.name, FieldStatus_Known, fmt.Sprintf("%q", f.value))
+		a.add(len(f.unknown), "Unknown", FieldStatus_Unknown, nil)
+	}
+}
+
+func (a *annotator) record(d *Dive) {
+	start := a.off
+	gap := func(end int) {
+		if n := start + end - a.off; n > 0 {
+			a.add(n, "Unknown", FieldStatus_Unknown, nil)
+		}
+	}
+
+	for _, f := range recordFields {
+		if f.offset+f.size > len(d.record) {
+			continue
+		}
+		gap(f.offset)
+		a.add(f.size, f.name, f.status, f.value(d))
+	}
+	gap(len(d.record))
+}
+
+// timeseries annotates the blocks of the timeseries data. The data may be
+// truncated or invalid: the bytes starting with the first block that cannot be
+// parsed are annotated as unknown.
+func (a *annotator) timeseries(data []byte) {
+	for i := 0; i < len(data); {
+		b := BlockType(data[i])
+		switch {
+		case fixedBlockSize[b] != 0 && i+1+fixedBlockSize[b] <= len(data):
+			size := 1 + fixedBlockSize[b]
+			p := data[i+1 : i+size]
+			a.add(size, fmt.Sprintf("block %v", b), FieldStatus_Unknown, fmt.Sprintf("payload %d", Event{Payload: p}.Value()))
+			i += size
+		case b == BlockType_Profile:
+			i = a.profile(data, i)
+		case b == BlockType_Extended && validExtended(data[i:]):
+			size, typ := int(data[i+1]), int(data[i+2])
+			p := data[i+3 : i+1+size]
+			status := FieldStatus_Unknown
+			var value interface{}
+			switch typ {
+			case ExtendedType_NoStop:
+				status = FieldStatus_Known
+				value = fmt.Sprintf("no-stop %v, MB no-stop %v", parseDurationMin(uint16(p[0])), parseDurationMin(uint16(p[1])))
+			case ExtendedType_Mixture:
+				status = FieldStatus_Uncertain
+				mix := parseGasMix(p)
+				value = fmt.Sprintf("%d%% O2, %d%% He, max pO2 %.2f", mix.PercentO2, mix.PercentHE, mix.MaxPO2)
+			}
+			a.add(1+size, fmt.Sprintf("block %v type %d", b, typ), status, value)
+			i += 1 + size
+		default:
+			a.add(len(data)-i, "Unknown", FieldStatus_Unknown, nil)
+			i = len(data)
+		}
+	}
+}
+
+// validExtended reports whether data starts with a complete 0xFB block whose
+// payload can be decoded.
+func validExtended(data []byte) bool {
+	if len(data) < 3 {
+		return false
+	}
+	size, typ := int(data[1]), int(data[2])
+	return size >= 2 && 1+size <= len(data) && size-2 >= minExtendedSize[typ]
+}
+
+// profile annotates the profile data starting with the 0xFA byte at data[i].
+// Unknown control bytes are annotated separately. It returns the offset of
+// the first byte following the profile data.
+func (a *annotator) profile(data []byte, i int) int {
+	name := fmt.Sprintf("block %v (profile)", BlockType_Profile)
+	start := i
+	flush := func(end int) {
+		if end > start {
+			a.add(end-start, name, FieldStatus_Known, fmt.Sprintf("%d bytes of profile data", end-start))
+		}
+	}
+
+	for i++; i < len(data) && BlockType(data[i]) != BlockType_Extended; i++ {
+		if isUnknownControlByte(data[i]) {
+			flush(i)
+			a.add(1, fmt.Sprintf("control byte %v", BlockType(data[i])), FieldStatus_Unknown, nil)
+			start = i + 1
+			name = "profile data"
+		}
+	}
+	flush(i)
+	return i
+}
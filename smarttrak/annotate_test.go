@@ -0,0 +1,143 @@
+package smarttrak
+
+import (
+	"testing"
+)
+
+func TestAnnotate(t *testing.T) {
+	data := testFileData()
+	fields := Annotate(data)
+	checkCoverage(t, fields, len(data))
+
+	byName := map[string]Field{}
+	for _, f := range fields {
+		if f.Dive == 2 {
+			byName[f.Name] = f
+		}
+	}
+
+	for name, want := range map[string]Field{
+		"Sequence":             {Status: FieldStatus_Known, Value: "12"},
+		"PPO2Limit":            {Status: FieldStatus_Uncertain, Value: "0"},
+		"block 0xF8":           {Status: FieldStatus_Unknown},
+		"block 0xFB type 26":   {Status: FieldStatus_Known, Value: "no-stop 16m0s, MB no-stop 8m0s"},
+		"control byte 0x81":    {Status: FieldStatus_Unknown},
+		"Trailer":              {Status: FieldStatus_Unknown},
+		"block 0xFA (profile)": {Status: FieldStatus_Known},
+		"Settings.Settings1":   {Status: FieldStatus_Uncertain},
+		"timeseries size":      {Status: FieldStatus_Known},
+		"block 0xFB type 32":   {Status: FieldStatus_Uncertain},
+		"DecoTemperature":      {Status: FieldStatus_Known, Value: "9"},
+		"Settings.FeatureSet":  {Status: FieldStatus_Uncertain, Value: "0x00000000"},
+	} {
+		got, ok := byName[name]
+		if !ok {
+			t.Errorf("field %q not found", name)
+			continue
+		}
+		if got.Status != want.Status || (want.Value != "" && got.Value != want.Value) {
+			t.Errorf("field %q = %+v, want status %v and value %q", name, got, want.Status, want.Value)
+		}
+	}
+}
+
+// checkCoverage checks that fields are consecutive and cover size bytes.
+func checkCoverage(t *testing.T, fields []Field, size int) {
+	t.Helper()
+
+	var off int
+	for _, f := range fields {
+		if f.Offset != off || f.Size <= 0 {
+			t.Fatalf("field %+v does not start at offset %d", f, off)
+		}
+		off += f.Size
+	}
+	if off != size {
+		t.Errorf("fields cover %d bytes, want %d", off, size)
+	}
+}
+
+func TestAnnotate_Unparsed(t *testing.T) {
+	header := len(testHeaderData())
+	record := header + 195
+	// The first block of the timeseries data is 0xF0 with a one byte
+	// payload.
+	badBlock := record + 2
+
+	cases := []struct {
+		name string
+		data func() []byte
+		// last is the field expected at the end, and unparsed the
+		// offset of the "Unparsed" field, or -1 if there is none.
+		last     string
+		unparsed int
+	}{
+		{"not an ASD file", func() []byte { return []byte("garbage") }, "Unparsed", 0},
+		{"truncated header", func() []byte { return testHeaderData()[:30] }, "Unparsed", 0},
+		{"truncated record", func() []byte { return testFileData()[:header+100] }, "Unparsed", header},
+		{"truncated timeseries", func() []byte { return testFileData()[:record+12] }, "Unknown", -1},
+		{"truncated trailer", func() []byte {
+			return testFileData()[:header+len(testDiveData())-3]
+		}, "Unparsed", header + len(testDiveData()) - 8},
+		{"invalid block", func() []byte {
+			data := testFileData()
+			data[badBlock] = 0xFB
+			data[badBlock+1] = 0x01
+			return data
+		}, "Unparsed", record + len(testTimeseries)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := tc.data()
+			fields := Annotate(data)
+			checkCoverage(t, fields, len(data))
+
+			var got []Field
+			for _, f := range fields {
+				if f.Name == "Unparsed" {
+					got = append(got, f)
+				}
+			}
+			switch {
+			case tc.unparsed < 0 && len(got) != 0:
+				t.Errorf("Annotate() = %+v, want no Unparsed field", got)
+			case tc.unparsed >= 0 && (len(got) != 1 || got[0].Offset != tc.unparsed || got[0].Value == ""):
+				t.Errorf("Annotate() = %+v, want one Unparsed field with an error at offset %d", got, tc.unparsed)
+			}
+			if last := fields[len(fields)-1]; last.Name != tc.last {
+				t.Errorf("last field = %+v, want %q", last, tc.last)
+			}
+		})
+	}
+}
+
+func TestRecordFields(t *testing.T) {
+	fields := RecordFields()
+	if len(fields) != len(recordFields) {
+		t.Fatalf("len(RecordFields()) = %d, want %d", len(fields), len(recordFields))
+	}
+
+	end := 0
+	byName := map[string]Field{}
+	for _, f := range fields {
+		if f.Offset < end || f.Size <= 0 || f.Offset+f.Size > defaultLayout.recordSize {
+			t.Errorf("field %+v overlaps the previous field or is outside the record", f)
+		}
+		if f.Dive != 0 || f.Value != "" {
+			t.Errorf("field %+v has Dive or Value set", f)
+		}
+		end = f.Offset + f.Size
+		byName[f.Name] = f
+	}
+
+	for _, want := range []Field{
+		{Offset: 8, Size: 4, Name: "DeviceID", Status: FieldStatus_Known},
+		{Offset: 60, Size: 2, Name: "PPO2Limit", Status: FieldStatus_Uncertain},
+		{Offset: 191, Size: 2, Name: "timeseries size", Status: FieldStatus_Known},
+	} {
+		if got := byName[want.Name]; got != want {
+			t.Errorf("RecordFields()[%q] = %+v, want %+v", want.Name, got, want)
+		}
+	}
+}
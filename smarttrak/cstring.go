@@ -0,0 +1,133 @@
+package smarttrak
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf16"
+)
+
+// maxStringLength is the maximum number of characters accepted by readString.
+// It protects against huge allocations when reading corrupt files.
+const maxStringLength = 1 << 20
+
+// readString reads a CString as serialized by MFC's CArchive. Both ANSI and
+// Unicode (UTF-16) strings are supported. ANSI strings are decoded as
+// ISO 8859-1.
+func (r *reader) readString() (string, error) {
+	start := r.off
+	length, unicode, err := r.readStringLength()
+	if err != nil {
+		return "", err
+	}
+	if length > maxStringLength {
+		return "", &ParseError{
+			Offset: start,
+			Err:    fmt.Errorf("string too long: %d characters", length),
+		}
+	}
+
+	if !unicode {
+		data, err := r.readExact(int(length))
+		if err != nil {
+			return "", err
+		}
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes), nil
+	}
+
+	data, err := r.readExact(2 * int(length))
+	if err != nil {
+		return "", err
+	}
+	units := make([]uint16, length)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+
+	return string(utf16.Decode(units)), nil
+}
+
+// readStringLength reads the length prefix of a CString, following the logic
+// of MFC's AfxReadStringLength: a length that does not fit into the current
+// integer size is escaped by the integer's maximum value, followed by the
+// next larger integer. The escape sequence 0xFF 0xFFFE marks Unicode strings.
+func (r *reader) readStringLength() (length uint64, unicode bool, err error) {
+	le := binary.LittleEndian
+
+	data, err := r.readExact(1)
+	if err != nil {
+		return 0, false, err
+	}
+	if data[0] < 0xFF {
+		return uint64(data[0]), false, nil
+	}
+
+	data, err = r.readExact(2)
+	if err != nil {
+		return 0, false, err
+	}
+	if le.Uint16(data) == 0xFFFE {
+		unicode = true
+
+		data, err = r.readExact(1)
+		if err != nil {
+			return 0, false, err
+		}
+		if data[0] < 0xFF {
+			return uint64(data[0]), unicode, nil
+		}
+
+		data, err = r.readExact(2)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+	if l := le.Uint16(data); l < 0xFFFF {
+		return uint64(l), unicode, nil
+	}
+
+	data, err = r.readExact(4)
+	if err != nil {
+		return 0, false, err
+	}
+	if l := le.Uint32(data); l < 0xFFFFFFFF {
+		return uint64(l), unicode, nil
+	}
+
+	data, err = r.readExact(8)
+	if err != nil {
+		return 0, false, err
+	}
+	return le.Uint64(data), unicode, nil
+}
+
+// writeString writes s as a Unicode CString, the format used by SmartTrak.
+func writeString(buf *bytes.Buffer, s string) error {
+	units := utf16.Encode([]rune(s))
+	if len(units) > maxStringLength {
+		return errors.New("string too long")
+	}
+
+	// Unicode marker
+	buf.Write([]byte{0xFF, 0xFE, 0xFF})
+
+	le := binary.LittleEndian
+	switch n := len(units); {
+	case n < 0xFF:
+		buf.WriteByte(byte(n))
+	case n < 0xFFFF:
+		buf.WriteByte(0xFF)
+		binary.Write(buf, le, uint16(n))
+	case n < math.MaxUint32:
+		buf.Write([]byte{0xFF, 0xFF, 0xFF})
+		binary.Write(buf, le, uint32(n))
+	}
+
+	return binary.Write(buf, le, units)
+}
@@ -0,0 +1,102 @@
+package smarttrak
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadString(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{
+			name: "unicode",
+			data: []byte{0xFF, 0xFE, 0xFF, 0x03, 'T', 0, 0xFC, 0, 'V', 0},
+			want: "TüV",
+		},
+		{
+			name: "ANSI",
+			data: []byte{0x04, 'S', 'e', 'e', 0xE9},
+			want: "Seeé",
+		},
+		{
+			name: "surrogate pair",
+			data: []byte{0xFF, 0xFE, 0xFF, 0x02, 0x3D, 0xD8, 0x20, 0xDC},
+			want: "🐠",
+		},
+		{
+			name: "16 bit length",
+			data: append([]byte{0xFF, 0xFE, 0xFF, 0xFF, 0x2C, 0x01}, bytes.Repeat([]byte{'x', 0}, 300)...),
+			want: strings.Repeat("x", 300),
+		},
+		{
+			name: "32 bit length",
+			data: append([]byte{0xFF, 0xFE, 0xFF, 0xFF, 0xFF, 0xFF, 0x70, 0x11, 0x01, 0x00}, bytes.Repeat([]byte{'x', 0}, 70000)...),
+			want: strings.Repeat("x", 70000),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &reader{r: bytes.NewReader(tc.data)}
+			got, err := r.readString()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("readString() = %q, want %q", got, tc.want)
+			}
+			if r.off != len(tc.data) {
+				t.Errorf("readString() consumed %d bytes, want %d", r.off, len(tc.data))
+			}
+		})
+	}
+}
+
+func TestReadString_Errors(t *testing.T) {
+	// Length of 2^32 characters, followed by no data.
+	data := []byte{0xFF, 0xFE, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00}
+	r := &reader{r: bytes.NewReader(data)}
+	var pe *ParseError
+	if _, err := r.readString(); !errors.As(err, &pe) {
+		t.Errorf("readString() = %v, want a *ParseError", err)
+	}
+
+	r = &reader{r: bytes.NewReader([]byte{0xFF, 0xFE, 0xFF, 0x05, 'x', 0})}
+	if _, err := r.readString(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("readString() = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestWriteString(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"Murner See",
+		"Tauchgang am Roten Meer 🐠🐙",
+		"Ägäis, Ελληνικά, 日本語",
+		strings.Repeat("Lange Notiz. ", 100),
+		strings.Repeat("🐠", 40000),
+	} {
+		var buf bytes.Buffer
+		if err := writeString(&buf, s); err != nil {
+			t.Fatal(err)
+		}
+
+		r := &reader{r: &buf}
+		got, err := r.readString()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != s {
+			t.Errorf("readString(writeString(%.20q)) = %.20q", s, got)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("readString(writeString(%.20q)) left %d bytes unread", s, buf.Len())
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package smarttrak
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Capabilities describes the optional features of a dive computer. They
+// determine which sample streams are expected in a dive's profile, see
+// Dive.PressureStream, and are checked against the dive by ToModel.
+type Capabilities struct {
+	// Transmitter is set if the computer receives the tank pressure from a
+	// transmitter.
+	Transmitter bool
+	// HeartRate is set if the computer records the heart rate.
+	HeartRate bool
+	// Trimix is set if the computer supports gas mixes containing helium.
+	Trimix bool
+}
+
+// Device describes the dive computer that recorded a dive.
+type Device struct {
+	// Model is the name of the dive computer model, e.g. "Galileo Sol". It
+	// is empty if the model is not known.
+	Model    string
+	Firmware string
+	// SampleInterval is the profile sample interval configured on the dive
+	// computer, or zero if it is not known.
+	SampleInterval time.Duration
+	Capabilities
+}
+
+func (d Device) String() string {
+	model := d.Model
+	if model == "" {
+		model = "unknown dive computer"
+	}
+	if d.Firmware != "" {
+		return fmt.Sprintf("%s (firmware %s)", model, d.Firmware)
+	}
+	return model
+}
+
+// StreamStatus describes whether an optional sample stream, such as the tank
+// pressure, is recorded in the timeseries data of a dive.
+type StreamStatus int
+
+const (
+	// StreamStatus_Unknown means that the dive computer is not registered,
+	// so it is not known whether it records the stream.
+	StreamStatus_Unknown StreamStatus = iota
+	// StreamStatus_Absent means that the dive computer does not record the
+	// stream.
+	StreamStatus_Absent
+	// StreamStatus_Expected means that the dive computer records the
+	// stream. Its encoding in the timeseries data is not known, so the
+	// samples are not decoded; they are among the Events that are not
+	// understood.
+	StreamStatus_Expected
+)
+
+func (s StreamStatus) String() string {
+	switch s {
+	case StreamStatus_Unknown:
+		return "unknown"
+	case StreamStatus_Absent:
+		return "absent"
+	case StreamStatus_Expected:
+		return "expected, not decoded"
+	}
+	return fmt.Sprintf("StreamStatus(%d)", int(s))
+}
+
+func streamStatus(d Device, recorded bool) StreamStatus {
+	switch {
+	case d == Device{}:
+		return StreamStatus_Unknown
+	case recorded:
+		return StreamStatus_Expected
+	}
+	return StreamStatus_Absent
+}
+
+// PressureStream reports whether the dive computer of d records the tank
+// pressure received from a transmitter, based on Device.Capabilities.
+func (d *Dive) PressureStream() StreamStatus {
+	return streamStatus(d.Device, d.Device.Transmitter)
+}
+
+// HeartRateStream reports whether the dive computer of d records the heart
+// rate, based on Device.Capabilities.
+func (d *Dive) HeartRateStream() StreamStatus {
+	return streamStatus(d.Device, d.Device.HeartRate)
+}
+
+// models holds the capabilities of the dive computer models supported by
+// SmartTrak, indexed by model name.
+var models = map[string]Capabilities{
+	"Smart Pro":      {},
+	"Smart Com":      {Transmitter: true},
+	"Smart Tec":      {Transmitter: true},
+	"Aladin Tec":     {},
+	"Aladin Tec 2G":  {},
+	"Aladin 2G":      {},
+	"Galileo Sol":    {Transmitter: true, HeartRate: true},
+	"Galileo Luna":   {Transmitter: true, HeartRate: true},
+	"Galileo Terra":  {},
+	"Galileo Trimix": {Transmitter: true, HeartRate: true, Trimix: true},
+	"Meridian":       {Transmitter: true, HeartRate: true},
+	"Mantis":         {Transmitter: true, HeartRate: true},
+	"Chromis":        {},
+}
+
+// Models returns the names of the dive computer models supported by
+// SmartTrak, sorted alphabetically.
+func Models() []string {
+	var ret []string
+	for name := range models {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// ModelDevice returns the Device of the given model, with the capabilities of
+// the model. ok is false if the model is not one of Models.
+func ModelDevice(model string) (d Device, ok bool) {
+	c, ok := models[model]
+	return Device{Model: model, Capabilities: c}, ok
+}
+
+// Registry maps device IDs and feature sets to dive computers.
+//
+// The device ID and the feature set are the only information about the dive
+// computer stored with each dive. Neither the model nor the firmware version
+// have been found in the file, and it is not known which feature sets
+// correspond to which model, so dive computers have to be registered by the
+// user. A Registry is safe for concurrent use.
+//
+// Registry implements flag.Value: Set accepts a comma separated list of
+// "<device ID>=<model>[/<sample interval>]" entries, e.g.
+// "0x00C0FFEE=Galileo Sol/10s", and takes the capabilities of the model from
+// ModelDevice.
+type Registry struct {
+	mu          sync.RWMutex
+	devices     map[uint32]Device
+	featureSets map[uint32]Device
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		devices:     make(map[uint32]Device),
+		featureSets: make(map[uint32]Device),
+	}
+}
+
+// AddDevice registers a single dive computer, identified by the device ID
+// stored with each dive.
+func (r *Registry) AddDevice(deviceID uint32, d Device) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.init()
+	r.devices[deviceID] = d
+}
+
+// AddFeatureSet registers the model, firmware and capabilities of all dive
+// computers reporting the given feature set.
+func (r *Registry) AddFeatureSet(featureSet uint32, d Device) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.init()
+	r.featureSets[featureSet] = d
+}
+
+// init allows the zero Registry to be used, e.g. as a flag.
+func (r *Registry) init() {
+	if r.devices == nil {
+		r.devices = make(map[uint32]Device)
+		r.featureSets = make(map[uint32]Device)
+	}
+}
+
+// Lookup returns the dive computer with the given device ID and feature set.
+// Devices registered with AddDevice take precedence over feature sets
+// registered with AddFeatureSet. If neither is known, ok is false. Lookup may
+// be called on a nil Registry.
+func (r *Registry) Lookup(deviceID, featureSet uint32) (d Device, ok bool) {
+	if r == nil {
+		return Device{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if d, ok = r.devices[deviceID]; !ok {
+		d, ok = r.featureSets[featureSet]
+	}
+	return d, ok
+}
+
+// String returns the devices registered with AddDevice in the format accepted
+// by Set.
+func (r *Registry) String() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var ret []string
+	for id, d := range r.devices {
+		s := fmt.Sprintf("%#08x=%s", id, d.Model)
+		if d.SampleInterval != 0 {
+			s += "/" + d.SampleInterval.String()
+		}
+		ret = append(ret, s)
+	}
+	sort.Strings(ret)
+	return strings.Join(ret, ",")
+}
+
+// Set registers the devices in s, a comma separated list of
+// "<device ID>=<model>[/<sample interval>]" entries. The model must be one
+// of Models.
+func (r *Registry) Set(s string) error {
+	for _, entry := range strings.Split(s, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		idStr, model, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid device %q, want <device ID>=<model>", entry)
+		}
+		id, err := strconv.ParseUint(strings.TrimSpace(idStr), 0, 32)
+		if err != nil {
+			return fmt.Errorf("invalid device ID %q: %w", idStr, err)
+		}
+		model, interval, hasInterval := strings.Cut(model, "/")
+		d, ok := ModelDevice(strings.TrimSpace(model))
+		if !ok {
+			return fmt.Errorf("unknown dive computer model %q, want one of %q", model, Models())
+		}
+		if hasInterval {
+			if d.SampleInterval, err = time.ParseDuration(strings.TrimSpace(interval)); err != nil || d.SampleInterval <= 0 {
+				return fmt.Errorf("invalid sample interval %q", interval)
+			}
+		}
+		r.AddDevice(uint32(id), d)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package smarttrak
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.AddFeatureSet(0x81, Device{
+		Model:        "Galileo Sol",
+		Capabilities: Capabilities{Transmitter: true, HeartRate: true},
+	})
+	r.AddDevice(0x00C0FFEE, Device{
+		Model:    "Aladin Tec",
+		Firmware: "1.2",
+	})
+
+	cases := []struct {
+		deviceID, featureSet uint32
+		want                 Device
+		wantOK               bool
+	}{
+		{0x00C0FFEE, 0x81, Device{Model: "Aladin Tec", Firmware: "1.2"}, true},
+		{0x1234, 0x81, Device{Model: "Galileo Sol", Capabilities: Capabilities{Transmitter: true, HeartRate: true}}, true},
+		{0x1234, 0x01, Device{}, false},
+	}
+
+	for _, tc := range cases {
+		got, ok := r.Lookup(tc.deviceID, tc.featureSet)
+		if diff := cmp.Diff(tc.want, got); diff != "" || ok != tc.wantOK {
+			t.Errorf("Lookup(%#x, %#x) = %v, want %v (-want/+got):\n%s", tc.deviceID, tc.featureSet, ok, tc.wantOK, diff)
+		}
+	}
+
+	var nilRegistry *Registry
+	if got, ok := nilRegistry.Lookup(0x00C0FFEE, 0x81); ok || got != (Device{}) {
+		t.Errorf("(*Registry)(nil).Lookup() = %v, %v, want the zero Device and false", got, ok)
+	}
+}
+
+func TestRegistry_Set(t *testing.T) {
+	var r Registry
+	if err := r.Set("0x00C0FFEE=Galileo Sol/10s, 4660=Aladin Tec"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := r.Lookup(0x00C0FFEE, 0)
+	want := Device{Model: "Galileo Sol", SampleInterval: 10 * time.Second, Capabilities: Capabilities{Transmitter: true, HeartRate: true}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Lookup() differs (-want/+got):\n%s", diff)
+	}
+	if got, want := r.String(), "0x00001234=Aladin Tec,0x00c0ffee=Galileo Sol/10s"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	for _, s := range []string{
+		"0x00C0FFEE",
+		"serial=Galileo Sol",
+		"0x00C0FFEE=Unknown Model",
+		"0x00C0FFEE=Galileo Sol/fast",
+	} {
+		if err := new(Registry).Set(s); err == nil {
+			t.Errorf("Set(%q) = nil, want an error", s)
+		}
+	}
+}
+
+func TestReader_Registry(t *testing.T) {
+	data := testDiveData()
+	binary.LittleEndian.PutUint32(data[35:], 0x81)
+
+	// The dive computer is not known without a Registry.
+	dive, err := ReadDive(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dive.Device != (Device{}) {
+		t.Errorf("ReadDive().Device = %v, want the zero Device", dive.Device)
+	}
+
+	r := NewReader(bytes.NewReader(data))
+	r.Registry = NewRegistry()
+	r.Registry.AddFeatureSet(0x81, Device{
+		Model:        "Galileo Sol",
+		Capabilities: Capabilities{Transmitter: true},
+	})
+	if dive, err = r.ReadDive(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Device{
+		Model:        "Galileo Sol",
+		Capabilities: Capabilities{Transmitter: true},
+	}
+	if diff := cmp.Diff(want, dive.Device); diff != "" {
+		t.Errorf("Device differs (-want/+got):\n%s", diff)
+	}
+}
+
+func TestDive_Streams(t *testing.T) {
+	for _, tc := range []struct {
+		device              Device
+		pressure, heartRate StreamStatus
+	}{
+		{Device{}, StreamStatus_Unknown, StreamStatus_Unknown},
+		{Device{Model: "Aladin Tec"}, StreamStatus_Absent, StreamStatus_Absent},
+		{Device{Model: "Smart Com", Capabilities: Capabilities{Transmitter: true}}, StreamStatus_Expected, StreamStatus_Absent},
+		{Device{Model: "Galileo Sol", Capabilities: Capabilities{Transmitter: true, HeartRate: true}}, StreamStatus_Expected, StreamStatus_Expected},
+	} {
+		d := &Dive{Device: tc.device}
+		if got := d.PressureStream(); got != tc.pressure {
+			t.Errorf("Dive{Device: %v}.PressureStream() = %v, want %v", tc.device, got, tc.pressure)
+		}
+		if got := d.HeartRateStream(); got != tc.heartRate {
+			t.Errorf("Dive{Device: %v}.HeartRateStream() = %v, want %v", tc.device, got, tc.heartRate)
+		}
+	}
+}
+
+func TestModels(t *testing.T) {
+	names := Models()
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("Models() = %q, want sorted names", names)
+	}
+	for _, name := range names {
+		if _, ok := ModelDevice(name); !ok {
+			t.Errorf("ModelDevice(%q) = false, want true", name)
+		}
+	}
+	if d, ok := ModelDevice("Galileo Trimix"); !ok || !d.Trimix {
+		t.Errorf("ModelDevice(\"Galileo Trimix\") = %+v, %v, want a trimix capable device", d, ok)
+	}
+	if _, ok := ModelDevice("Unknown Model"); ok {
+		t.Error("ModelDevice(\"Unknown Model\") = true, want false")
+	}
+}
@@ -3,9 +3,10 @@ package smarttrak
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"math"
 	"time"
 )
 
@@ -24,9 +25,42 @@ func (wt WaterType) Density() float64 {
 	return float64(wt)
 }
 
+// TemperatureConfidence describes how reliable the temperatures in a dive's
+// profile are. The profile only records temperature changes, so absolute
+// temperatures have to be reconstructed from the dive record.
+type TemperatureConfidence int
+
+const (
+	// TemperatureConfidence_None means that no temperature could be
+	// determined. All profile temperatures are zero.
+	TemperatureConfidence_None TemperatureConfidence = iota
+	// TemperatureConfidence_Low means that the profile temperatures are
+	// anchored at DecoTemperature or AirTemperature, or that the size of a
+	// temperature step had to be assumed.
+	TemperatureConfidence_Low
+	// TemperatureConfidence_High means that the profile temperatures are
+	// consistent with MinTemperature and MaxTemperature.
+	TemperatureConfidence_High
+)
+
+func (c TemperatureConfidence) String() string {
+	switch c {
+	case TemperatureConfidence_None:
+		return "none"
+	case TemperatureConfidence_Low:
+		return "low"
+	case TemperatureConfidence_High:
+		return "high"
+	}
+	return fmt.Sprintf("TemperatureConfidence(%d)", int(c))
+}
+
 // Dive contains information about a single dive.
 type Dive struct {
-	DeviceID        uint32
+	DeviceID uint32
+	// Device is the dive computer that recorded the dive, as returned by
+	// Reader.Registry. It is empty if the dive computer is not registered.
+	Device          Device
 	Sequence        int
 	Time            time.Time
 	Duration        time.Duration
@@ -45,21 +79,42 @@ type Dive struct {
 	TankWarning     float64
 	TankReserve     float64
 	Profile         []DataPoint
-	PercentO2       int
-	PercentHE       int
-
-	// Unparsed
+	// TemperatureConfidence describes how the temperatures in Profile have
+	// been reconstructed.
+	TemperatureConfidence TemperatureConfidence
+	// SampleInterval is the time between two data points in Profile. It
+	// is taken from Device if set there, and inferred from Duration and
+	// the number of data points otherwise.
+	SampleInterval time.Duration
+	// PercentO2 and PercentHE describe the gas mix used at the start of
+	// the dive, i.e. GasMixes[0].
+	PercentO2 int
+	PercentHE int
+	GasMixes  []GasMix
+	Events    []Event
+	Settings  Settings
+	// PPO2Limit is probably the configured maximum partial pressure of
+	// oxygen in bar. See Settings for the other settings.
+	PPO2Limit float64
+
+	// Unparsed. WorkSensitivity is probably the workload sensitivity
+	// setting, its scale is not known.
 	WorkSensitivity uint16
 	DesatBefore     uint16
-	featureSet      uint32
-	settings1       settings1
-	settings2       uint32
-
-	// Not certain
-	maxPO2 float64
+	// Trailer holds the bytes following the timeseries data. Their meaning
+	// is not known. They have not been identified as a checksum of the
+	// dive, so they are not verified; "correlate-asd -trailer" tests
+	// checksum hypotheses against them. Writer writes them back unchanged,
+	// or writes zeros if Trailer does not have the expected size.
+	Trailer []byte
 
 	// internal
 	timeseriesSize uint16
+	tempScale      tempScale
+	// record and timeseries hold the raw data read from the file, so that
+	// unknown fields can be written back unchanged.
+	record     []byte
+	timeseries []byte
 }
 
 // DataPoint holds timeseries data points.
@@ -71,6 +126,13 @@ type DataPoint struct {
 	Warning      bool
 	HighWorkload bool
 	Bookmark     bool
+	// NoStopTime is the remaining no-decompression time calculated by the
+	// computer. MBNoStopTime is the no-decompression time for the
+	// configured microbubble level. Both are zero until the computer
+	// records them for the first time. The decompression ceiling has not
+	// been located, see ExtendedType_NoStop.
+	NoStopTime   time.Duration
+	MBNoStopTime time.Duration
 }
 
 // State returns a string representation of the DataPoint's state.
@@ -93,26 +155,85 @@ func (d DataPoint) State() string {
 	return ret
 }
 
+// DefaultDepthTolerance is the difference between the maximum depth of the
+// profile and MaxDepth, in meters, that is considered normal.
+const DefaultDepthTolerance = 0.1
+
+// CheckProfile verifies that the maximum depth of the profile matches MaxDepth
+// within tolerance meters. If not, a *DepthMismatchError is returned.
+func (d *Dive) CheckProfile(tolerance float64) error {
+	if len(d.Profile) == 0 {
+		return nil
+	}
+
+	var max float64
+	for _, p := range d.Profile {
+		if p.Depth > max {
+			max = p.Depth
+		}
+	}
+
+	if math.Abs(max-d.MaxDepth) > tolerance {
+		return &DepthMismatchError{
+			MaxDepth:        d.MaxDepth,
+			ProfileMaxDepth: max,
+		}
+	}
+	return nil
+}
+
+// Record returns a copy of the fixed size record the dive has been parsed
+// from. It is nil for dives that have not been read from a file.
+func (d *Dive) Record() []byte {
+	if d.record == nil {
+		return nil
+	}
+	return append([]byte(nil), d.record...)
+}
+
+// Timeseries returns a copy of the timeseries data the dive has been parsed
+// from. It is nil for dives that have not been read from a file.
+func (d *Dive) Timeseries() []byte {
+	if d.timeseries == nil {
+		return nil
+	}
+	return append([]byte(nil), d.timeseries...)
+}
+
+// ReadDive reads a single dive of a version 7 file from r. It returns io.EOF
+// if r is at EOF before the dive starts. If the dive is truncated or
+// malformed, a *ParseError is returned.
 func ReadDive(r io.Reader) (*Dive, error) {
-	data, err := readExact(r, 195)
+	return NewReader(r).ReadDive()
+}
+
+func readDive(r *reader, l layout) (*Dive, error) {
+	data, err := r.readFirst(l.recordSize)
 	if err != nil {
 		return nil, err
 	}
-	dive, err := ParseDive(data)
+	dive, err := parseRecord(data, l)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err = readExact(r, int(dive.timeseriesSize))
+	tsStart := r.off
+	data, err = r.readExact(int(dive.timeseriesSize))
 	if err != nil {
 		return nil, err
 	}
 
+	dive.timeseries = data
+
 	if err := dive.parseTimeseriesBlock(data); err != nil {
+		var pe *ParseError
+		if errors.As(err, &pe) {
+			pe.Offset += tsStart
+		}
 		return nil, err
 	}
 
-	_, err = readExact(r, 8)
+	dive.Trailer, err = r.readExact(l.trailerSize)
 	if err != nil {
 		return nil, err
 	}
@@ -120,15 +241,28 @@ func ReadDive(r io.Reader) (*Dive, error) {
 	return dive, nil
 }
 
-// ParseDive parses the
+// ParseDive parses the fixed size record at the start of each dive.
 func ParseDive(data []byte) (*Dive, error) {
-	if got, want := len(data), 195; got != want {
-		return nil, fmt.Errorf("unexpected size: got %d, want %d", got, want)
+	return parseRecord(data, defaultLayout)
+}
+
+func parseRecord(data []byte, l layout) (*Dive, error) {
+	if got, want := len(data), l.recordSize; got != want {
+		return nil, &ParseError{
+			Want: want,
+			Got:  got,
+			Err:  errors.New("unexpected record size"),
+		}
 	}
 
 	le := binary.LittleEndian
 
-	s1 := settings1(le.Uint32(data[82:]))
+	settings := Settings{
+		FeatureSet: le.Uint32(data[35:]),
+		Settings1:  le.Uint32(data[82:]),
+		Settings2:  le.Uint32(data[167:]),
+	}
+	wt := settings.WaterType()
 
 	dive := &Dive{
 		DeviceID:        le.Uint32(data[8:]),
@@ -137,7 +271,6 @@ func ParseDive(data []byte) (*Dive, error) {
 		Duration:        parseDurationMin(le.Uint16(data[44:])),
 		SurfaceInterval: parseDurationMin(le.Uint16(data[50:])),
 		TimeLimit:       parseDurationMin(le.Uint16(data[33:])),
-		featureSet:      le.Uint32(data[35:]),
 		AirTemperature:  parseTemperature(le.Uint16(data[30:])),
 		DecoTemperature: parseTemperature(le.Uint16(data[70:])),
 		MinTemperature:  parseTemperature(le.Uint16(data[46:])),
@@ -146,169 +279,30 @@ func ParseDive(data []byte) (*Dive, error) {
 		PressureEnd:     parsePressure(le.Uint16(data[56:])),
 		TankWarning:     parsePressure(le.Uint16(data[64:])),
 		TankReserve:     parsePressure(le.Uint16(data[66:])),
-		WaterType:       s1.WaterType(),
-		MaxDepth:        parseDepth(le.Uint16(data[42:]), s1.WaterType()),
-		AverageDepth:    parseDepth(le.Uint16(data[158:]), s1.WaterType()),
-		DepthLimit:      parseDepth(le.Uint16(data[62:]), s1.WaterType()),
+		WaterType:       wt,
+		MaxDepth:        parseDepth(le.Uint16(data[42:]), wt),
+		AverageDepth:    parseDepth(le.Uint16(data[158:]), wt),
+		DepthLimit:      parseDepth(le.Uint16(data[62:]), wt),
+		Settings:        settings,
 		// unparsed
 		WorkSensitivity: le.Uint16(data[68:]),
 		DesatBefore:     le.Uint16(data[72:]),
-		settings1:       s1,
-		settings2:       le.Uint32(data[167:]),
 		// not certain
-		maxPO2:         float64(le.Uint16(data[60:])) / 1000.0,
+		PPO2Limit:      float64(le.Uint16(data[60:])) / 1000.0,
 		timeseriesSize: le.Uint16(data[191:]),
+		record:         data,
 	}
 
 	return dive, nil
 }
 
-func (d *Dive) parseTimeseriesBlock(data []byte) error {
-	for i := 0; i < len(data); i++ {
-		b := data[i]
-
-		switch {
-		case b == 0xf0:
-			log.Printf("0xF0 => %d", data[i+1])
-			i += 1
-		case b == 0xf2:
-			log.Printf("0xF2 => %d", data[i+1])
-			i += 1
-		case b == 0xf3:
-			log.Printf("0xF3 => %d", binary.LittleEndian.Uint16(data[i+1:]))
-			i += 2
-		case b == 0xf4:
-			log.Printf("0xF4 => %d", binary.LittleEndian.Uint16(data[i+1:]))
-			i += 2
-		case b == 0xf8:
-			log.Printf("0xF8 => %d", binary.LittleEndian.Uint32(data[i+1:]))
-			i += 4
-		case b == 0xf9:
-			log.Printf("0xF9 => %d", data[i+1])
-			i += 1
-		case b == 0xfa:
-			len, err := d.parseTimeseries(data[i+1:])
-			if err != nil {
-				return err
-			}
-			i += len
-		case b == 0xfb:
-			len := int(data[i+1])
-			switch typ := int(data[i+2]); typ {
-			case 32:
-				pctO2 := binary.LittleEndian.Uint16(data[i+3:])
-				pctHE := binary.LittleEndian.Uint16(data[i+5:])
-				d.PercentO2 = int(pctO2)
-				d.PercentHE = int(pctHE)
-				log.Printf("Mixture: %d%% O₂, %d%% He", pctO2, pctHE)
-				log.Printf("Maybe max pO₂: %d", binary.LittleEndian.Uint16(data[i+11:]))
-			case 26:
-				log.Printf("NOSTOPMIN:   %d", data[i+3])
-				log.Printf("NOSTOPMBMIN: %d", data[i+4])
-			default:
-				log.Printf("Unknown block: type %d, payload %d bytes", typ, len-2)
-			}
-			i += len
-		default:
-			return fmt.Errorf("unexpected byte %#x at location %d", b, i)
-		}
-	}
-	return nil
-}
-
-func (d *Dive) parseTimeseries(data []byte) (n int, err error) {
-	const interval = 4 * time.Second
-
-	state := DataPoint{
-		Time: d.Time,
-	}
-
-	// Temperature is recorded relatively, i.e. in changes to the previous temperature.
-	// Unfortunately, I have been unable to identify a "start temperature" in the binary data.
-	// We record the up and down steps in the data, and later use the
-	// MinTemperature and MaxTemperature fields to scale the fields correctly.
-	var minTemp, maxTemp, currTemp int
-
-	// ret contains the bytes that were read. Set when a 0xFB byte is read.
-	var ret int
-
-BYTE:
-	for i := 0; i < len(data); i++ {
-		b := data[i]
-
-		switch {
-		case b&0xf0 == 0xb0:
-			raw := uint8(b & 0x0f)
-			if raw&0x08 != 0 {
-				raw |= 0xf0
-			}
-			currTemp += int(int8(raw))
-			if minTemp > currTemp {
-				minTemp = currTemp
-			}
-			if maxTemp < currTemp {
-				maxTemp = currTemp
-			}
-
-			state.Temperature = float64(currTemp)
-		case b&0xf0 == 0xc0:
-			n := int(b & 0x0f)
-			for i := 0; i < n; i++ {
-				d.Profile = append(d.Profile, state)
-				state.Time = state.Time.Add(interval)
-			}
-		case b&0xf0 == 0xe0:
-			if b&0x02 != 0 {
-				state.Alert = true
-			}
-			if b&0x01 != 0 {
-				state.Warning = true
-			}
-			if b&0x04 != 0 {
-				state.HighWorkload = true
-			}
-			if b&0x08 != 0 {
-				state.Bookmark = true
-			}
-			if b&0x0f == 0 {
-				state.Alert = false
-				state.Warning = false
-				state.HighWorkload = false
-				state.Bookmark = false
-			}
-		case b == 0xfb:
-			ret = i
-			break BYTE
-		case b&0x80 != 0:
-			fmt.Printf("Unknown control byte: %#02X\n", b)
-		default:
-			diff := parseDepthDiff(b)
-
-			state.Depth += diff
-			d.Profile = append(d.Profile, state)
-			state.Time = state.Time.Add(interval)
-		}
-	}
-
-	// scale temperatures correctly
-	if minTemp != maxTemp {
-		fact := (d.MaxTemperature - d.MinTemperature) / float64(maxTemp-minTemp)
-		offset := float64(minTemp)
-		for i := range d.Profile {
-			d.Profile[i].Temperature = d.MinTemperature + (fact * (d.Profile[i].Temperature - offset))
-		}
-	}
-
-	return ret, nil
-}
-
 func parseTime(data []byte) time.Time {
 	// t is half-seconds since 2000-01-01
 	t := int64(binary.LittleEndian.Uint64(data[0:8]))
 	t = timeOffset + t/2
 
 	// offset is the time-zone offset in 15m increments.
-	offset := int(binary.LittleEndian.Uint16(data[8:10]))
+	offset := int(int16(binary.LittleEndian.Uint16(data[8:10])))
 	offset *= 900 // 15 min
 
 	return time.Unix(t, 0).In(time.FixedZone("Device/Local", offset))
@@ -330,19 +324,18 @@ func parseDepth(depth uint16, wt WaterType) float64 {
 	return 10.0 * float64(depth) / wt.Density()
 }
 
-func parseDepthDiff(d byte) float64 {
+// parseDepthDiff returns the depth change encoded in a profile byte, in units
+// of the profile depth.
+func parseDepthDiff(d byte) int {
 	// copy bit 7 to bit 8 so that when we cast to a signed int,
 	// the signedness is interpreted correctly.
 	d |= ((d & 0x40) << 1)
-	return float64(int8(d)) / 50.0
+	return int(int8(d))
 }
 
-type settings1 uint32
-
-func (s settings1) WaterType() WaterType {
-	const isSaltWater = 0x00100000
-	if s&isSaltWater != 0 {
-		return WaterType_Salt
-	}
-	return WaterType_Sweet
+// parseProfileDepth converts a profile depth to meters. The profile depth is
+// measured in steps of 2cm of fresh water, i.e. the water pressure is
+// recorded. Like parseDepth, this takes the water density into account.
+func parseProfileDepth(depth int, wt WaterType) float64 {
+	return 20.0 * float64(depth) / wt.Density()
 }
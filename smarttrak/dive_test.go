@@ -0,0 +1,199 @@
+package smarttrak
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// testTimeseries is the timeseries data used by testDiveData. It contains
+// each of the known control blocks.
+var testTimeseries = []byte{
+	0xF0, 0x01,
+	0xF3, 0x10, 0x00,
+	0xF8, 0x01, 0x02, 0x03, 0x04,
+	0xFB, 0x0C, 32, 21, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x40, 0x06,
+	0xFA,
+	0x00, 0x32, 0xB1, 0x32,
+	0xFB, 0x04, 26, 0x10, 0x08,
+	0xFB, 0x0C, 32, 50, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x40, 0x06,
+	0xFA,
+	0x19, 0xE2, 0xC3, 0xE0, 0x00, 0x7F, 0xBF, 0x4E, 0x81, 0x4E,
+}
+
+// testDiveData returns a single dive, consisting of the fixed size record, the
+// timeseries data and the trailing bytes.
+func testDiveData() []byte {
+	le := binary.LittleEndian
+
+	rec := make([]byte, 195)
+	le.PutUint32(rec[8:], 0x00C0FFEE)
+	le.PutUint64(rec[16:], uint64(2*(time.Date(2021, time.October, 17, 9, 15, 16, 0, time.UTC).Unix()-timeOffset)))
+	le.PutUint16(rec[24:], 8) // +02:00
+	le.PutUint16(rec[28:], 12)
+	le.PutUint16(rec[30:], 114)
+	le.PutUint16(rec[33:], 99)
+	le.PutUint16(rec[42:], 250)
+	le.PutUint16(rec[44:], 1)
+	le.PutUint16(rec[46:], 88)
+	le.PutUint16(rec[54:], 200*128)
+	le.PutUint16(rec[56:], 50*128)
+	le.PutUint16(rec[70:], 90)
+	le.PutUint16(rec[158:], 150)
+	le.PutUint16(rec[160:], 110)
+	le.PutUint16(rec[191:], uint16(len(testTimeseries)))
+
+	data := append(rec, testTimeseries...)
+	return append(data, make([]byte, 8)...)
+}
+
+func TestReadDive(t *testing.T) {
+	dive, err := ReadDive(bytes.NewReader(testDiveData()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := dive.Sequence, 12; got != want {
+		t.Errorf("Sequence = %d, want %d", got, want)
+	}
+	if got, want := dive.PercentO2, 21; got != want {
+		t.Errorf("PercentO2 = %d, want %d", got, want)
+	}
+	if got, want := len(dive.Profile), 11; got != want {
+		t.Errorf("len(Profile) = %d, want %d", got, want)
+	}
+	if got, want := len(dive.Events), 7; got != want {
+		t.Errorf("len(Events) = %d, want %d", got, want)
+	}
+	wantMixes := []GasMix{
+		{Index: 0, Time: dive.Time, PercentO2: 21, MaxPO2: 1.6},
+		{Index: 3, Time: dive.Time.Add(12 * time.Second), PercentO2: 50, MaxPO2: 1.6},
+	}
+	if diff := cmp.Diff(wantMixes, dive.GasMixes); diff != "" {
+		t.Errorf("GasMixes differ (-want/+got):\n%s", diff)
+	}
+	if got, want := dive.Profile[2].NoStopTime, time.Duration(0); got != want {
+		t.Errorf("Profile[2].NoStopTime = %v, want %v", got, want)
+	}
+	if got, want := dive.Profile[3].NoStopTime, 16*time.Minute; got != want {
+		t.Errorf("Profile[3].NoStopTime = %v, want %v", got, want)
+	}
+	if got, want := dive.Profile[3].MBNoStopTime, 8*time.Minute; got != want {
+		t.Errorf("Profile[3].MBNoStopTime = %v, want %v", got, want)
+	}
+}
+
+func TestCheckProfile(t *testing.T) {
+	for _, wt := range []WaterType{WaterType_Sweet, WaterType_Salt} {
+		data := testDiveData()
+		if wt == WaterType_Salt {
+			binary.LittleEndian.PutUint32(data[82:], 0x00100000)
+		}
+
+		dive, err := ReadDive(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dive.WaterType != wt {
+			t.Fatalf("WaterType = %v, want %v", dive.WaterType, wt)
+		}
+		if err := dive.CheckProfile(0.01); err != nil {
+			t.Errorf("CheckProfile() = %v", err)
+		}
+
+		dive.MaxDepth += 1.0
+		var dme *DepthMismatchError
+		if err := dive.CheckProfile(DefaultDepthTolerance); !errors.As(err, &dme) {
+			t.Errorf("CheckProfile() = %v, want a *DepthMismatchError", err)
+		}
+	}
+}
+
+func TestReadDive_Errors(t *testing.T) {
+	data := testDiveData()
+
+	for n := 1; n < len(data); n++ {
+		_, err := ReadDive(bytes.NewReader(data[:n]))
+		var pe *ParseError
+		if !errors.As(err, &pe) || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("ReadDive(data[:%d]) = %v, want a *ParseError wrapping io.ErrUnexpectedEOF", n, err)
+		}
+	}
+
+	if _, err := ReadDive(bytes.NewReader(nil)); err != io.EOF {
+		t.Errorf("ReadDive(empty) = %v, want io.EOF", err)
+	}
+
+	// Truncate the 0xF8 block.
+	data = testDiveData()
+	binary.LittleEndian.PutUint16(data[191:], 8)
+	_, err := ReadDive(bytes.NewReader(data))
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("ReadDive() = %v, want a *ParseError", err)
+	}
+	want := ParseError{
+		Offset: 195 + 5,
+		Block:  BlockType_F8,
+		Want:   4,
+		Got:    2,
+		Err:    io.ErrUnexpectedEOF,
+	}
+	if *pe != want {
+		t.Errorf("ReadDive() = %#v, want %#v", *pe, want)
+	}
+}
+
+func FuzzReadDive(f *testing.F) {
+	f.Add(testDiveData())
+	f.Add(append(make([]byte, 195), testTimeseries...))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Use the fuzzer's data as timeseries, too, to exercise the
+		// timeseries parser with arbitrary block sizes.
+		if len(data) >= 195 {
+			binary.LittleEndian.PutUint16(data[191:], uint16(len(data)-195-8))
+		}
+
+		_, err := ReadDive(bytes.NewReader(data))
+		var pe *ParseError
+		if err != nil && err != io.EOF && !errors.As(err, &pe) {
+			t.Errorf("ReadDive() = %v, want a *ParseError", err)
+		}
+	})
+}
+
+func TestDive_Record(t *testing.T) {
+	data := testDiveData()
+	d, err := ReadDive(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := d.Record()
+	if !bytes.Equal(rec, data[:195]) {
+		t.Errorf("Record() = % X, want % X", rec, data[:195])
+	}
+
+	// Record returns a copy.
+	rec[28] = 0xFF
+	if got := d.Record()[28]; got != data[28] {
+		t.Errorf("Record()[28] = %#x after modifying the returned slice, want %#x", got, data[28])
+	}
+
+	if got := (&Dive{}).Record(); got != nil {
+		t.Errorf("Record() = % X for a new dive, want nil", got)
+	}
+
+	if got := d.Timeseries(); !bytes.Equal(got, testTimeseries) {
+		t.Errorf("Timeseries() = % X, want % X", got, testTimeseries)
+	}
+	if got := (&Dive{}).Timeseries(); got != nil {
+		t.Errorf("Timeseries() = % X for a new dive, want nil", got)
+	}
+}
@@ -0,0 +1,86 @@
+package smarttrak
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ParseError is returned when an ASD file is truncated or malformed.
+type ParseError struct {
+	// Offset is the position at which the error was detected. It is
+	// relative to the start of the data passed to ReadHeader, ReadDive or
+	// ParseDive. ReadLogbook reports offsets relative to the start of the
+	// file.
+	Offset int
+	// Block is the type of the timeseries block being parsed, or zero if
+	// the error occurred outside of the timeseries data.
+	Block BlockType
+	// Want and Got are the expected and the actual number of bytes. Both
+	// are zero if the error was not caused by missing data.
+	Want, Got int
+	// Err is the underlying error, for example io.ErrUnexpectedEOF.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "offset %d", e.Offset)
+	if e.Block != 0 {
+		fmt.Fprintf(&b, ": block %v", e.Block)
+	}
+	if e.Want != 0 || e.Got != 0 {
+		fmt.Fprintf(&b, ": got %d bytes, want %d", e.Got, e.Want)
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&b, ": %v", e.Err)
+	}
+	return b.String()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// DepthMismatchError is returned by Dive.CheckProfile if the maximum depth of
+// the profile does not match the dive's MaxDepth.
+type DepthMismatchError struct {
+	MaxDepth        float64
+	ProfileMaxDepth float64
+}
+
+func (e *DepthMismatchError) Error() string {
+	return fmt.Sprintf("maximum profile depth %.2fm does not match maximum depth %.2fm", e.ProfileMaxDepth, e.MaxDepth)
+}
+
+// SampleIntervalError is returned by Writer.WriteDive if the dive's
+// SampleInterval would not be read back. The interval is not stored in the
+// file: it is taken from the registered Device, or inferred from the duration
+// and the number of data points.
+type SampleIntervalError struct {
+	SampleInterval time.Duration
+	// Read is the sample interval the dive would be read with.
+	Read time.Duration
+}
+
+func (e *SampleIntervalError) Error() string {
+	return fmt.Sprintf("sample interval %v cannot be stored, the dive would be read with %v; set Device.SampleInterval", e.SampleInterval, e.Read)
+}
+
+// UnsupportedVersionError is returned when the file has been written by a
+// version of SmartTrak whose format is not supported.
+type UnsupportedVersionError struct {
+	Version int
+	// ClassName is the class name stored in the file header, if the
+	// version is supported but the class name is not the expected one. It
+	// is empty otherwise: the class name is not read if the version, or
+	// the length of the class name, is not supported.
+	ClassName string
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	if e.ClassName == "" {
+		return fmt.Sprintf("unsupported version %d", e.Version)
+	}
+	return fmt.Sprintf("unsupported version %d (class %q)", e.Version, e.ClassName)
+}
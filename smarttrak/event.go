@@ -0,0 +1,78 @@
+package smarttrak
+
+import "fmt"
+
+// BlockType is the control byte that starts a block in the timeseries data.
+type BlockType uint8
+
+const (
+	BlockType_F0       BlockType = 0xF0
+	BlockType_F2       BlockType = 0xF2
+	BlockType_F3       BlockType = 0xF3
+	BlockType_F4       BlockType = 0xF4
+	BlockType_F8       BlockType = 0xF8
+	BlockType_F9       BlockType = 0xF9
+	BlockType_Profile  BlockType = 0xFA
+	BlockType_Extended BlockType = 0xFB
+)
+
+// Types of extended (0xFB) blocks.
+const (
+	// ExtendedType_NoStop holds the no-stop time and the no-stop time at
+	// the configured microbubble level, in minutes. It is decoded into
+	// DataPoint.NoStopTime and DataPoint.MBNoStopTime, and Writer adds
+	// these blocks where the no-stop times of the profile change.
+	//
+	// The block does not hold a decompression ceiling: all blocks seen so
+	// far have a two byte payload, holding the two no-stop times. Where
+	// the ceiling is stored during decompression dives is not known.
+	ExtendedType_NoStop = 26
+	// ExtendedType_Mixture holds the breathing gas.
+	ExtendedType_Mixture = 32
+)
+
+func (t BlockType) String() string {
+	return fmt.Sprintf("0x%02X", uint8(t))
+}
+
+// Event is a control block in the timeseries data. Most of these blocks are
+// not understood yet; they are kept so that callers can inspect them.
+//
+// Unknown control bytes within the profile data are reported as events with
+// an empty payload.
+type Event struct {
+	// Offset is the position of the control byte in the timeseries data.
+	Offset int
+	// Index is the number of data points in Dive.Profile preceding the
+	// event, i.e. the event applies to Profile[Index] and later.
+	Index int
+	Type  BlockType
+	// ExtendedType is the block type of BlockType_Extended (0xFB) blocks
+	// and zero for all other blocks.
+	ExtendedType int
+	// Payload is the raw data following the control byte. For extended
+	// blocks, the length and type bytes are not included.
+	Payload []byte
+}
+
+// Value returns the payload interpreted as a little endian unsigned
+// integer. Only the first eight bytes of the payload are considered.
+func (e Event) Value() uint64 {
+	p := e.Payload
+	if len(p) > 8 {
+		p = p[:8]
+	}
+
+	var v uint64
+	for i := len(p) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(p[i])
+	}
+	return v
+}
+
+func (e Event) String() string {
+	if e.Type == BlockType_Extended {
+		return fmt.Sprintf("%v type %d at %d: % X", e.Type, e.ExtendedType, e.Offset, e.Payload)
+	}
+	return fmt.Sprintf("%v at %d: % X", e.Type, e.Offset, e.Payload)
+}
@@ -0,0 +1,66 @@
+package smarttrak
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEvents(t *testing.T) {
+	data := []byte{
+		/* 0 */ 0xF0, 0x01,
+		/* 2 */ 0xF2, 0x02,
+		/* 4 */ 0xF3, 0x03, 0x04,
+		/* 7 */ 0xF4, 0x05, 0x06,
+		/* 10 */ 0xF8, 0x07, 0x08, 0x09, 0x0A,
+		/* 15 */ 0xF9, 0x0B,
+		/* 17 */ 0xFA, 0x00,
+		/* 19 */ 0xF1, 0x01,
+		/* 21 */ 0xF5, 0xF6, 0x01,
+		/* 24 */ 0xF7,
+		/* 25 */ 0xFB, 0x04, 26, 0x10, 0x08,
+		/* 30 */ 0xFB, 0x03, 99, 0x0C,
+	}
+
+	d := &Dive{}
+	if err := d.parseTimeseriesBlock(data); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Event{
+		{Offset: 0, Index: 0, Type: BlockType_F0, Payload: []byte{0x01}},
+		{Offset: 2, Index: 0, Type: BlockType_F2, Payload: []byte{0x02}},
+		{Offset: 4, Index: 0, Type: BlockType_F3, Payload: []byte{0x03, 0x04}},
+		{Offset: 7, Index: 0, Type: BlockType_F4, Payload: []byte{0x05, 0x06}},
+		{Offset: 10, Index: 0, Type: BlockType_F8, Payload: []byte{0x07, 0x08, 0x09, 0x0A}},
+		{Offset: 15, Index: 0, Type: BlockType_F9, Payload: []byte{0x0B}},
+		{Offset: 19, Index: 1, Type: 0xF1},
+		{Offset: 21, Index: 2, Type: 0xF5},
+		{Offset: 22, Index: 2, Type: 0xF6},
+		{Offset: 24, Index: 3, Type: 0xF7},
+		{Offset: 25, Index: 3, Type: BlockType_Extended, ExtendedType: ExtendedType_NoStop, Payload: []byte{0x10, 0x08}},
+		{Offset: 30, Index: 3, Type: BlockType_Extended, ExtendedType: 99, Payload: []byte{0x0C}},
+	}
+	if diff := cmp.Diff(want, d.Events); diff != "" {
+		t.Errorf("Events differ (-want/+got):\n%s", diff)
+	}
+
+	if got, want := d.Events[4].Value(), uint64(0x0A090807); got != want {
+		t.Errorf("Events[4].Value() = %#x, want %#x", got, want)
+	}
+}
+
+func TestBlockType_String(t *testing.T) {
+	for _, tc := range []struct {
+		t    BlockType
+		want string
+	}{
+		{BlockType_F0, "0xF0"},
+		{BlockType_Extended, "0xFB"},
+		{0x01, "0x01"},
+	} {
+		if got := tc.t.String(); got != tc.want {
+			t.Errorf("BlockType(%d).String() = %q, want %q", uint8(tc.t), got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package smarttrak
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// GasMix is a breathing gas used during the dive.
+type GasMix struct {
+	// Index is the number of data points in Dive.Profile preceding the
+	// switch to this gas, i.e. the gas is used from Profile[Index] on.
+	Index int
+	// Time is the time at which the gas became active.
+	Time      time.Time
+	PercentO2 int
+	PercentHE int
+	// MaxPO2 is probably the maximum partial pressure of oxygen
+	// configured for this gas, in bar. It is zero if not recorded.
+	MaxPO2 float64
+}
+
+// gasMixSize is the payload size of mixture blocks written by SmartTrak.
+const gasMixSize = 10
+
+func parseGasMix(p []byte) GasMix {
+	le := binary.LittleEndian
+
+	mix := GasMix{
+		PercentO2: int(le.Uint16(p[0:])),
+		PercentHE: int(le.Uint16(p[2:])),
+	}
+	if len(p) >= gasMixSize {
+		mix.MaxPO2 = float64(le.Uint16(p[8:])) / 1000.0
+	}
+	return mix
+}
+
+// encode updates the payload of a mixture block with the values of mix. The
+// returned slice is a copy; unknown bytes of p are retained.
+func (mix GasMix) encode(p []byte) []byte {
+	ret := make([]byte, gasMixSize)
+	if len(p) > gasMixSize {
+		ret = make([]byte, len(p))
+	}
+	copy(ret, p)
+
+	le := binary.LittleEndian
+	le.PutUint16(ret[0:], uint16(mix.PercentO2))
+	le.PutUint16(ret[2:], uint16(mix.PercentHE))
+	le.PutUint16(ret[8:], uint16(math.Round(mix.MaxPO2*1000.0)))
+	return ret
+}
+
+func isMixture(e Event) bool {
+	return e.Type == BlockType_Extended && e.ExtendedType == ExtendedType_Mixture
+}
+
+// eventsForWriting returns the events to encode, with the mixture blocks
+// updated to match GasMixes, PercentO2 and PercentHE.
+//
+// If the number of gas mixes and their positions are unchanged, the mixture
+// blocks are updated in place. Otherwise, all mixture blocks are replaced by
+// new ones at the positions given by GasMix.Index.
+func (d *Dive) eventsForWriting() []Event {
+	mixes := append([]GasMix(nil), d.GasMixes...)
+	if len(mixes) == 0 && (d.PercentO2 != 0 || d.PercentHE != 0) {
+		mixes = append(mixes, GasMix{})
+	}
+	if len(mixes) != 0 {
+		mixes[0].PercentO2 = d.PercentO2
+		mixes[0].PercentHE = d.PercentHE
+	}
+
+	var (
+		events   []Event
+		payloads [][]byte
+		moved    bool
+	)
+	for _, e := range d.Events {
+		if isMixture(e) {
+			n := len(payloads)
+			moved = moved || n >= len(mixes) || mixes[n].Index != e.Index
+			payloads = append(payloads, e.Payload)
+			continue
+		}
+		events = append(events, e)
+	}
+
+	if len(payloads) == len(mixes) && !moved {
+		events = append(events[:0:0], d.Events...)
+		n := 0
+		for i, e := range events {
+			if isMixture(e) {
+				events[i].Payload = mixes[n].encode(e.Payload)
+				n++
+			}
+		}
+		return events
+	}
+
+	for i, mix := range mixes {
+		var p []byte
+		if i < len(payloads) {
+			p = payloads[i]
+		}
+		events = insertEvent(events, Event{
+			Index:        mix.Index,
+			Type:         BlockType_Extended,
+			ExtendedType: ExtendedType_Mixture,
+			Payload:      mix.encode(p),
+		})
+	}
+	return events
+}
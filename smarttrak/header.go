@@ -1,118 +1,166 @@
 package smarttrak
 
 import (
-	"bytes"
 	"encoding/binary"
-	"fmt"
 	"io"
-	"log"
 )
 
+const (
+	defaultVersion   = 7
+	defaultClassName = "CTravelTrakCEDoc"
+)
+
+// Header is the file header of an .asd file.
+//
+// The header is only partially decoded: besides the strings, only the format
+// version and the class name are known. The owner data and the unit setting
+// are assumed to be stored in the header, but they have not been located, see
+// Unknown1, Unknown2 and Unknown3.
 type Header struct {
-	Name string
+	// Version is the number stored at the very start of the file. It is
+	// probably the schema number of the serialized MFC document class, and
+	// determines the layout of the header and the dives. Only version 7 is
+	// supported.
+	Version int
+	// ClassName is the name of the MFC document class, "CTravelTrakCEDoc".
+	ClassName string
+	Name      string
+	SuitType  string
+	Weather   string
+
+	// Unknown1, Unknown2 and Unknown3 hold the bytes following Name,
+	// SuitType and Weather, respectively. None of them has been decoded.
+	// They are written back unchanged by Writer, and "correlate-asd
+	// -header" compares them byte by byte across files.
+	//
+	// Unknown1 (38 bytes in version 7) was expected to hold the owner data
+	// entered in SmartTrak next to the logbook name. This could not be
+	// verified: it needs files whose owner data is known to differ.
+	//
+	// Unknown2 (2 bytes) was expected to hold the unit setting. This could
+	// not be verified either, for the same reason.
+	//
+	// Unknown3 (27 bytes) precedes the first dive. Nothing is known about
+	// it.
+	//
+	// The 16 bytes preceding Name are the class name, see ClassName.
+	Unknown1 []byte
+	Unknown2 []byte
+	Unknown3 []byte
 }
 
+// ReadHeader reads the file header from r. If the file has been written in an
+// unsupported format version, or with a different document class, the
+// returned *ParseError wraps an *UnsupportedVersionError.
 func ReadHeader(r io.Reader) (*Header, error) {
-	magic, err := readExact(r, 4)
-	if err != nil {
-		return nil, err
-	}
-	if !bytes.Equal(magic, []byte{0x07, 0x00, 0x10, 0x00}) {
-		return nil, fmt.Errorf("not an ASD file")
-	}
+	h, _, err := readHeader(&reader{r: r})
+	return h, err
+}
 
-	// skip 16 bytes "CTravelTrakCEDoc "
-	_, err = readExact(r, 16)
+// readHeader reads the file header and returns it together with the layout
+// of the file's version.
+func readHeader(r *reader) (*Header, layout, error) {
+	start := r.off
+	data, err := r.readExact(4)
 	if err != nil {
-		return nil, err
+		return nil, layout{}, err
 	}
-
-	name, err := readString(r)
-	if err != nil {
-		return nil, err
+	version := int(binary.LittleEndian.Uint16(data[0:]))
+	nameLen := int(binary.LittleEndian.Uint16(data[2:]))
+
+	// The version and the length of the class name are checked before
+	// reading the class name, so that files of other formats are reported
+	// as unsupported rather than as truncated.
+	l, err := layoutFor(version)
+	if err == nil && nameLen != len(l.className) {
+		err = &UnsupportedVersionError{Version: version}
 	}
-
-	// skip 38 bytes
-	_, err = readExact(r, 38)
 	if err != nil {
-		return nil, err
-	}
-
-	suitType, err := readString(r)
-	if err != nil {
-		return nil, err
+		return nil, layout{}, &ParseError{
+			Offset: start,
+			Err:    err,
+		}
 	}
-	log.Println("Suit type:", suitType)
 
-	// skip 2 bytes
-	_, err = readExact(r, 2)
+	className, err := r.readExact(nameLen)
 	if err != nil {
-		return nil, err
+		return nil, layout{}, err
 	}
-
-	weather, err := readString(r)
-	if err != nil {
-		return nil, err
+	if string(className) != l.className {
+		return nil, layout{}, &ParseError{
+			Offset: start,
+			Err: &UnsupportedVersionError{
+				Version:   version,
+				ClassName: string(className),
+			},
+		}
 	}
-	log.Println("Weather:", weather)
 
-	// skip 27 bytes
-	_, err = readExact(r, 27)
-	if err != nil {
-		return nil, err
+	var (
+		strs    [3]string
+		unknown [3][]byte
+	)
+	for i := range strs {
+		if strs[i], err = r.readString(); err != nil {
+			return nil, layout{}, err
+		}
+		if unknown[i], err = r.readExact(l.headerUnknown[i]); err != nil {
+			return nil, layout{}, err
+		}
 	}
 
 	return &Header{
-		Name: name,
-	}, nil
+		Version:   version,
+		ClassName: string(className),
+		Name:      strs[0],
+		SuitType:  strs[1],
+		Weather:   strs[2],
+		Unknown1:  unknown[0],
+		Unknown2:  unknown[1],
+		Unknown3:  unknown[2],
+	}, l, nil
 }
 
-func readString(r io.Reader) (string, error) {
-	header, err := readExact(r, 4)
-	if err != nil {
-		return "", err
-	}
-
-	if !bytes.Equal(header[:3], []byte{0xFF, 0xFE, 0xFF}) {
-		return "", fmt.Errorf("unexpected string header")
-	}
-
-	length := int(header[3])
-	var runes []rune
-	for i := 0; i < length; i++ {
-		char, err := readUint16(r)
-		if err != nil {
-			return "", err
-		}
-		runes = append(runes, rune(char))
-	}
-
-	return string(runes), nil
+// reader wraps an io.Reader and keeps track of the number of bytes read, so
+// that errors can report the offset at which they occurred.
+type reader struct {
+	r   io.Reader
+	off int
 }
 
-func readUint16(r io.Reader) (uint16, error) {
-	data, err := readExact(r, 2)
-	if err != nil {
-		return 0, err
+// readExact reads exactly length bytes. If fewer bytes are available, a
+// *ParseError wrapping io.ErrUnexpectedEOF is returned.
+func (r *reader) readExact(length int) ([]byte, error) {
+	data, err := r.readFirst(length)
+	if err == io.EOF {
+		return nil, &ParseError{
+			Offset: r.off,
+			Want:   length,
+			Err:    io.ErrUnexpectedEOF,
+		}
 	}
-
-	return binary.LittleEndian.Uint16(data), nil
+	return data, err
 }
 
-func readExact(r io.Reader, length int) ([]byte, error) {
+// readFirst is like readExact, but returns io.EOF if no bytes could be read
+// because the end of the input has been reached. It is used to read the first
+// bytes of a structure.
+func (r *reader) readFirst(length int) ([]byte, error) {
 	data := make([]byte, length)
 
-	var read int
-	for read < length {
-		n, err := r.Read(data[read:])
-		if err != nil {
-			return nil, err
-		}
-		if n == 0 {
-			return nil, fmt.Errorf("got %d bytes, want %d: %w", read, length, io.EOF)
+	n, err := io.ReadFull(r.r, data)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, &ParseError{
+			Offset: r.off,
+			Want:   length,
+			Got:    n,
+			Err:    err,
 		}
-		read += n
 	}
+	r.off += n
 
 	return data, nil
 }
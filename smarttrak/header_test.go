@@ -0,0 +1,161 @@
+package smarttrak
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// writeTestString appends s to b in the format used by SmartTrak.
+func writeTestString(b *bytes.Buffer, s string) {
+	b.Write([]byte{0xFF, 0xFE, 0xFF, byte(len(s))})
+	for _, r := range s {
+		binary.Write(b, binary.LittleEndian, uint16(r))
+	}
+}
+
+// testHeaderData returns a minimal, valid file header.
+func testHeaderData() []byte {
+	var b bytes.Buffer
+	b.Write([]byte{0x07, 0x00, 0x10, 0x00})
+	b.WriteString("CTravelTrakCEDoc")
+	writeTestString(&b, "Logbook")
+	b.Write(make([]byte, 38))
+	writeTestString(&b, "Wetsuit")
+	b.Write(make([]byte, 2))
+	writeTestString(&b, "Sunny")
+	b.Write(make([]byte, 27))
+	return b.Bytes()
+}
+
+func TestReadHeader(t *testing.T) {
+	hdr, err := ReadHeader(bytes.NewReader(testHeaderData()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Header{
+		Version:   7,
+		ClassName: "CTravelTrakCEDoc",
+		Name:      "Logbook",
+		SuitType:  "Wetsuit",
+		Weather:   "Sunny",
+		Unknown1:  make([]byte, 38),
+		Unknown2:  make([]byte, 2),
+		Unknown3:  make([]byte, 27),
+	}
+	if diff := cmp.Diff(want, hdr); diff != "" {
+		t.Errorf("ReadHeader(): results differ (-want/+got):\n%s", diff)
+	}
+}
+
+func TestReadHeader_Version(t *testing.T) {
+	data := testHeaderData()
+	data[0] = 8
+
+	_, err := ReadHeader(bytes.NewReader(data))
+	var uve *UnsupportedVersionError
+	if !errors.As(err, &uve) || uve.Version != 8 {
+		t.Fatalf("ReadHeader() = %v, want an *UnsupportedVersionError", err)
+	}
+	if got, want := uve.Error(), "unsupported version 8"; got != want {
+		t.Errorf("UnsupportedVersionError.Error() = %q, want %q", got, want)
+	}
+
+	// A different class name, or class name length, is an unsupported
+	// version, too.
+	data = testHeaderData()
+	data[4] = 'X'
+	_, err = ReadHeader(bytes.NewReader(data))
+	if !errors.As(err, &uve) || uve.Version != 7 || uve.ClassName != "XTravelTrakCEDoc" {
+		t.Errorf("ReadHeader() = %v, want an *UnsupportedVersionError for class \"XTravelTrakCEDoc\"", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "unsupported version 7") {
+		t.Errorf("ReadHeader() = %v, want an \"unsupported version 7\" error", err)
+	}
+
+	data = testHeaderData()
+	data[2] = 15
+	if _, err := ReadHeader(bytes.NewReader(data)); !errors.As(err, &uve) || uve.Version != 7 {
+		t.Errorf("ReadHeader() = %v, want an *UnsupportedVersionError for version 7", err)
+	}
+
+	// Files of other formats are unsupported, not truncated.
+	for _, data := range [][]byte{
+		[]byte("<html><head><title>Dives</title></head></html>"),
+		{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n', 0x00, 0x00, 0x00, 0x0D, 'I', 'H', 'D', 'R'},
+		{0x07, 0x00, 0xFF, 0xFF},
+	} {
+		_, err := ReadHeader(bytes.NewReader(data))
+		if !errors.As(err, &uve) {
+			t.Errorf("ReadHeader(%q) = %v, want an *UnsupportedVersionError", data, err)
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("ReadHeader(%q) = %v, want no io.ErrUnexpectedEOF", data, err)
+		}
+	}
+
+	if err := NewWriter(io.Discard).WriteHeader(&Header{Version: 8}); !errors.As(err, &uve) {
+		t.Errorf("WriteHeader() = %v, want an *UnsupportedVersionError", err)
+	}
+}
+
+func FuzzReadHeader(f *testing.F) {
+	f.Add(testHeaderData())
+	f.Add([]byte{0x07, 0x00, 0x10, 0x00})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, err := ReadHeader(bytes.NewReader(data))
+		var pe *ParseError
+		if err != nil && !errors.As(err, &pe) {
+			t.Errorf("ReadHeader() = %v, want a *ParseError", err)
+		}
+	})
+}
+
+func TestReadHeader_Unknown(t *testing.T) {
+	// Fill each region with a distinct pattern, so that a region that is
+	// read at the wrong position or with the wrong size is detected.
+	pattern := func(n int, b byte) []byte {
+		return bytes.Repeat([]byte{b}, n)
+	}
+	var b bytes.Buffer
+	b.Write([]byte{0x07, 0x00, 0x10, 0x00})
+	b.WriteString("CTravelTrakCEDoc")
+	writeTestString(&b, "Logbook")
+	b.Write(pattern(38, 0x11))
+	writeTestString(&b, "Wetsuit")
+	b.Write(pattern(2, 0x22))
+	writeTestString(&b, "Sunny")
+	b.Write(pattern(27, 0x33))
+	data := b.Bytes()
+
+	hdr, err := ReadHeader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []struct {
+		name      string
+		got, want []byte
+	}{
+		{"Unknown1", hdr.Unknown1, pattern(38, 0x11)},
+		{"Unknown2", hdr.Unknown2, pattern(2, 0x22)},
+		{"Unknown3", hdr.Unknown3, pattern(27, 0x33)},
+	} {
+		if !bytes.Equal(r.got, r.want) {
+			t.Errorf("%s = % X, want % X", r.name, r.got, r.want)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := NewWriter(&out).WriteHeader(hdr); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Errorf("WriteHeader() = % X, want % X", out.Bytes(), data)
+	}
+}
@@ -0,0 +1,47 @@
+package smarttrak
+
+// layout describes the parts of the file format that depend on the file
+// version.
+//
+// Only version 7 has been seen so far. Files with other versions or class
+// names are rejected with an *UnsupportedVersionError until samples are
+// available to add their layout here.
+type layout struct {
+	// className is the name of the MFC document class following the
+	// version.
+	className string
+	// headerUnknown are the sizes of the unknown blocks following the
+	// name, suit type and weather strings in the header.
+	headerUnknown [3]int
+	// recordSize is the size of the fixed size record at the start of each
+	// dive.
+	recordSize int
+	// trailerSize is the number of bytes following the timeseries data of
+	// each dive.
+	trailerSize int
+}
+
+// layouts maps the file version to the corresponding layout.
+var layouts = map[int]layout{
+	7: {
+		className:     defaultClassName,
+		headerUnknown: [3]int{38, 2, 27},
+		recordSize:    195,
+		trailerSize:   8,
+	},
+}
+
+// defaultLayout is the layout of files written by Writer and of dives read
+// with ReadDive.
+var defaultLayout = layouts[defaultVersion]
+
+// layoutFor returns the layout of the given file version.
+func layoutFor(version int) (layout, error) {
+	l, ok := layouts[version]
+	if !ok {
+		return layout{}, &UnsupportedVersionError{
+			Version: version,
+		}
+	}
+	return l, nil
+}
@@ -0,0 +1,90 @@
+package smarttrak
+
+import (
+	"fmt"
+	"io"
+)
+
+// Logbook is the content of an .asd file: a header followed by any number of
+// dives.
+type Logbook struct {
+	Header *Header
+	Dives  []*Dive
+}
+
+// Reader reads SmartTrak .asd files.
+type Reader struct {
+	// Registry identifies the dive computer of each dive, see Dive.Device.
+	// If nil, Dive.Device is left empty.
+	Registry *Registry
+
+	r      *reader
+	layout layout
+}
+
+// NewReader returns a new Reader reading from r. Dives are read in the format
+// of version 7, unless ReadHeader reads a header first.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		r:      &reader{r: r},
+		layout: defaultLayout,
+	}
+}
+
+// ReadLogbook reads the header and all dives from r until EOF is reached.
+// A *ParseError wrapping io.ErrUnexpectedEOF is returned if the file ends in
+// the middle of a dive. Offsets in errors are relative to the start of the
+// file.
+func ReadLogbook(r io.Reader) (*Logbook, error) {
+	return NewReader(r).ReadLogbook()
+}
+
+// ReadLogbook reads the header and all dives until EOF is reached. See the
+// ReadLogbook function for details.
+func (r *Reader) ReadLogbook() (*Logbook, error) {
+	hdr, err := r.ReadHeader()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	lb := &Logbook{
+		Header: hdr,
+	}
+
+	for {
+		dive, err := r.ReadDive()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading dive #%d: %w", len(lb.Dives)+1, err)
+		}
+		lb.Dives = append(lb.Dives, dive)
+	}
+
+	return lb, nil
+}
+
+// ReadHeader reads the file header and selects the format of the following
+// dives. See the ReadHeader function for details.
+func (r *Reader) ReadHeader() (*Header, error) {
+	hdr, l, err := readHeader(r.r)
+	if err != nil {
+		return nil, err
+	}
+	r.layout = l
+	return hdr, nil
+}
+
+// ReadDive reads the next dive. See the ReadDive function for details.
+func (r *Reader) ReadDive() (*Dive, error) {
+	dive, err := readDive(r.r, r.layout)
+	if err != nil {
+		return nil, err
+	}
+	dive.Device, _ = r.Registry.Lookup(dive.DeviceID, dive.Settings.FeatureSet)
+	if dive.Device.SampleInterval != 0 {
+		dive.setSampleTimes(dive.Device.SampleInterval)
+	}
+	return dive, nil
+}
@@ -0,0 +1,78 @@
+package smarttrak
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadLogbook(t *testing.T) {
+	hdr := testHeaderData()
+	file := append(append(append([]byte{}, hdr...), testDiveData()...), testDiveData()...)
+
+	lb, err := ReadLogbook(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(lb.Dives), 2; got != want {
+		t.Errorf("len(Dives) = %d, want %d", got, want)
+	}
+
+	// Truncate the second dive in the middle of its record.
+	diveSize := len(testDiveData())
+	_, err = ReadLogbook(bytes.NewReader(file[:len(hdr)+diveSize+100]))
+	var pe *ParseError
+	if !errors.As(err, &pe) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("ReadLogbook() = %v, want a *ParseError wrapping io.ErrUnexpectedEOF", err)
+	}
+	if got, want := pe.Offset, len(hdr)+diveSize; got != want {
+		t.Errorf("ParseError.Offset = %d, want %d", got, want)
+	}
+}
+
+func TestReadLogbook_Dives(t *testing.T) {
+	hdr := testHeaderData()
+	second := testDiveData()
+	binary.LittleEndian.PutUint16(second[28:], 13)
+
+	file := append(append([]byte{}, hdr...), testDiveData()...)
+	file = append(file, second...)
+
+	lb, err := ReadLogbook(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, d := range lb.Dives {
+		got = append(got, d.Sequence)
+	}
+	if diff := cmp.Diff([]int{12, 13}, got); diff != "" {
+		t.Errorf("Sequence of the dives differs (-want/+got):\n%s", diff)
+	}
+
+	// A file without dives is valid.
+	lb, err = ReadLogbook(bytes.NewReader(hdr))
+	if err != nil || len(lb.Dives) != 0 {
+		t.Errorf("ReadLogbook(header only) = %v, %v, want no dives", lb, err)
+	}
+
+	// Truncating the second dive anywhere, i.e. in the record, the
+	// timeseries data or the trailer, is an error.
+	start := len(file) - len(second)
+	for n := start + 1; n < len(file); n++ {
+		_, err := ReadLogbook(bytes.NewReader(file[:n]))
+		var pe *ParseError
+		if !errors.As(err, &pe) || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("ReadLogbook(file[:%d]) = %v, want a *ParseError wrapping io.ErrUnexpectedEOF", n, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), "dive #2") {
+			t.Errorf("ReadLogbook(file[:%d]) = %v, want the error to name dive #2", n, err)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package smarttrak
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/octo/divelogs-go/divemodel"
+)
+
+// ToModel converts d to the format-neutral dive model. The returned report
+// lists the fields that could not be converted and the assumptions made.
+//
+// Each gas mix is reported as a separate cylinder. The tank pressures are
+// assigned to the first cylinder. DecoTemperature is reported as the water
+// temperature at the end of the dive.
+func ToModel(d *Dive) (*divemodel.Dive, divemodel.Report) {
+	m := &divemodel.Dive{
+		Number:          d.Sequence,
+		Time:            d.Time,
+		Duration:        d.Duration,
+		SurfaceInterval: d.SurfaceInterval,
+		MaxDepth:        d.MaxDepth,
+		MeanDepth:       d.AverageDepth,
+		Density:         d.WaterType.Density(),
+		Temperatures: divemodel.Temperatures{
+			Air:      d.AirTemperature,
+			WaterMin: d.MinTemperature,
+			WaterMax: d.MaxTemperature,
+			End:      d.DecoTemperature,
+		},
+		Computer: divemodel.Computer{
+			Model:    d.Device.Model,
+			ID:       fmt.Sprintf("%#08x", d.DeviceID),
+			Firmware: d.Device.Firmware,
+		},
+		SampleInterval: d.SampleInterval,
+	}
+
+	mixes := d.GasMixes
+	if len(mixes) == 0 {
+		mixes = []GasMix{{
+			Time:      d.Time,
+			PercentO2: d.PercentO2,
+			PercentHE: d.PercentHE,
+		}}
+	}
+	for i, mix := range mixes {
+		m.Cylinders = append(m.Cylinders, divemodel.Cylinder{
+			Gas: divemodel.Gas{
+				PercentO2: float64(mix.PercentO2),
+				PercentHe: float64(mix.PercentHE),
+				MaxPO2:    mix.MaxPO2,
+			},
+		})
+		if i > 0 {
+			m.GasChanges = append(m.GasChanges, divemodel.GasChange{
+				Time:     mix.Time.Sub(d.Time),
+				Cylinder: i,
+			})
+		}
+	}
+	m.Cylinders[0].StartPressure = d.PressureStart
+	m.Cylinders[0].EndPressure = d.PressureEnd
+
+	var prev DataPoint
+	for _, p := range d.Profile {
+		s := divemodel.Sample{
+			Time:        p.Time.Sub(d.Time),
+			Depth:       p.Depth,
+			Temperature: p.Temperature,
+			NoStopTime:  p.NoStopTime,
+		}
+		m.Samples = append(m.Samples, s)
+
+		// Flags are reported as events when they are set.
+		for _, f := range []struct {
+			set, wasSet bool
+			typ         divemodel.EventType
+		}{
+			{p.Bookmark, prev.Bookmark, divemodel.EventType_Bookmark},
+			{p.Warning, prev.Warning, divemodel.EventType_Warning},
+			{p.Alert, prev.Alert, divemodel.EventType_Alert},
+			{p.HighWorkload, prev.HighWorkload, divemodel.EventType_HighWorkload},
+		} {
+			if f.set && !f.wasSet {
+				m.Events = append(m.Events, divemodel.Event{
+					Time: s.Time,
+					Type: f.typ,
+				})
+			}
+		}
+		prev = p
+	}
+
+	return m, toModelReport(d)
+}
+
+func toModelReport(d *Dive) divemodel.Report {
+	var r divemodel.Report
+	drop := func(set bool, field, format string, args ...interface{}) {
+		if set {
+			r.Add(divemodel.ReportKind_Dropped, field, format, args...)
+		}
+	}
+
+	drop(d.TimeLimit != 0, "TimeLimit", "%v", d.TimeLimit)
+	drop(d.DepthLimit != 0, "DepthLimit", "%.1f m", d.DepthLimit)
+	drop(d.TankWarning != 0, "TankWarning", "%.1f bar", d.TankWarning)
+	drop(d.TankReserve != 0, "TankReserve", "%.1f bar", d.TankReserve)
+	drop(d.PPO2Limit != 0, "PPO2Limit", "%.2f bar", d.PPO2Limit)
+	drop(d.WorkSensitivity != 0, "WorkSensitivity", "%d", d.WorkSensitivity)
+	drop(d.DesatBefore != 0, "DesatBefore", "%d", d.DesatBefore)
+	drop(len(d.Settings.UnknownBits()) != 0, "Settings", "unknown bits %v", d.Settings.UnknownBits())
+
+	var unknownEvents int
+	for _, e := range d.Events {
+		if e.Type != BlockType_Extended || (e.ExtendedType != ExtendedType_NoStop && e.ExtendedType != ExtendedType_Mixture) {
+			unknownEvents++
+		}
+	}
+	drop(unknownEvents != 0, "Events", "%d blocks that are not understood", unknownEvents)
+
+	for _, p := range d.Profile {
+		if p.MBNoStopTime != 0 {
+			drop(true, "Profile.MBNoStopTime", "microbubble no-stop times")
+			break
+		}
+	}
+	drop(d.PressureStream() == StreamStatus_Expected, "Profile.Pressure", "recorded by the %v, but not decoded", d.Device)
+	drop(d.HeartRateStream() == StreamStatus_Expected, "Profile.HeartRate", "recorded by the %v, but not decoded", d.Device)
+
+	heuristic := func(field, format string, args ...interface{}) {
+		r.Add(divemodel.ReportKind_Heuristic, field, format, args...)
+	}
+	if d.DecoTemperature != 0 {
+		heuristic("DecoTemperature", "assumed to be the water temperature at the end of the dive")
+	}
+	if len(d.Profile) != 0 && d.Device.SampleInterval == 0 {
+		heuristic("SampleInterval", "%v, derived from the duration and the number of samples", d.SampleInterval)
+	}
+	if len(d.Profile) != 0 {
+		if d.TemperatureConfidence != TemperatureConfidence_High {
+			heuristic("Profile.Temperature", "reconstructed with %v confidence", d.TemperatureConfidence)
+		}
+	}
+	if len(d.GasMixes) > 1 && (d.PressureStart != 0 || d.PressureEnd != 0) {
+		heuristic("PressureStart", "assigned to the first of %d gas mixes", len(d.GasMixes))
+	}
+	if d.Device.Model != "" && !d.Device.Trimix && (d.PercentHE != 0 || hasHelium(d.GasMixes)) {
+		heuristic("Device", "the %v does not support trimix, but the dive uses helium; the registered model may be wrong", d.Device)
+	}
+
+	return r
+}
+
+// FromModel converts the format-neutral dive model to a Dive. The returned
+// report lists the fields that could not be converted and the assumptions
+// made.
+//
+// Water with a density closer to 1025 g/l than to 1000 g/l is considered salt
+// water. Events are set as flags on the sample at the time of the event.
+// Tank pressure and heart rate samples are dropped, because their encoding
+// is not known.
+func FromModel(m *divemodel.Dive) (*Dive, divemodel.Report) {
+	r := fromModelReport(m)
+
+	wt := WaterType_Sweet
+	if m.Density > (WaterType_Sweet.Density()+WaterType_Salt.Density())/2 {
+		wt = WaterType_Salt
+	}
+	switch {
+	case m.Density == 0:
+		r.Add(divemodel.ReportKind_Defaulted, "WaterType", "%v, the water density is not known", wt)
+	case m.Density != wt.Density():
+		r.Add(divemodel.ReportKind_Heuristic, "Density", "%g g/l mapped to %v", m.Density, wt)
+	}
+
+	id, err := strconv.ParseUint(m.Computer.ID, 0, 32)
+	if err != nil && m.Computer.ID != "" {
+		r.Add(divemodel.ReportKind_Dropped, "Computer.ID", "%q is not a SmartTrak device ID", m.Computer.ID)
+	}
+
+	device, _ := ModelDevice(m.Computer.Model)
+	if m.Computer.Model == "" {
+		device = Device{}
+	}
+	device.Firmware = m.Computer.Firmware
+
+	d := &Dive{
+		DeviceID:        uint32(id),
+		Device:          device,
+		Sequence:        m.Number,
+		Time:            m.Time,
+		Duration:        m.Duration,
+		SurfaceInterval: m.SurfaceInterval,
+		WaterType:       wt,
+		MaxDepth:        m.MaxDepth,
+		AverageDepth:    m.MeanDepth,
+		AirTemperature:  m.Temperatures.Air,
+		DecoTemperature: m.Temperatures.End,
+		MinTemperature:  m.Temperatures.WaterMin,
+		MaxTemperature:  m.Temperatures.WaterMax,
+		SampleInterval:  m.SampleInterval,
+		Settings:        Settings{}.withWaterType(wt),
+	}
+
+	// sampleIndex returns the index of the first sample at or after t.
+	sampleIndex := func(t time.Duration) int {
+		return sort.Search(len(m.Samples), func(i int) bool {
+			return m.Samples[i].Time >= t
+		})
+	}
+
+	for _, s := range m.Samples {
+		d.Profile = append(d.Profile, DataPoint{
+			Time:        m.Time.Add(s.Time),
+			Depth:       s.Depth,
+			Temperature: s.Temperature,
+			NoStopTime:  s.NoStopTime,
+		})
+	}
+
+	for _, e := range m.Events {
+		i := sampleIndex(e.Time)
+		if i >= len(d.Profile) {
+			r.Add(divemodel.ReportKind_Dropped, "Events", "%v at %v is after the last sample", e.Type, e.Time)
+			continue
+		}
+		p := &d.Profile[i]
+		switch e.Type {
+		case divemodel.EventType_Bookmark:
+			p.Bookmark = true
+		case divemodel.EventType_Warning:
+			p.Warning = true
+		case divemodel.EventType_Alert:
+			p.Alert = true
+		case divemodel.EventType_HighWorkload:
+			p.HighWorkload = true
+		}
+	}
+
+	if len(m.Cylinders) == 0 {
+		return d, r
+	}
+
+	d.PressureStart = m.Cylinders[0].StartPressure
+	d.PressureEnd = m.Cylinders[0].EndPressure
+
+	addMix := func(t time.Duration, c divemodel.Cylinder) {
+		d.GasMixes = append(d.GasMixes, GasMix{
+			Index:     sampleIndex(t),
+			Time:      m.Time.Add(t),
+			PercentO2: int(math.Round(c.Gas.PercentO2)),
+			PercentHE: int(math.Round(c.Gas.PercentHe)),
+			MaxPO2:    c.Gas.MaxPO2,
+		})
+	}
+	addMix(0, m.Cylinders[0])
+	for _, gc := range m.GasChanges {
+		if gc.Cylinder < 0 || gc.Cylinder >= len(m.Cylinders) {
+			r.Add(divemodel.ReportKind_Dropped, "GasChanges", "switch to unknown cylinder %d at %v", gc.Cylinder, gc.Time)
+			continue
+		}
+		addMix(gc.Time, m.Cylinders[gc.Cylinder])
+	}
+	d.PercentO2 = d.GasMixes[0].PercentO2
+	d.PercentHE = d.GasMixes[0].PercentHE
+
+	return d, r
+}
+
+func hasHelium(mixes []GasMix) bool {
+	for _, mix := range mixes {
+		if mix.PercentHE != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func fromModelReport(m *divemodel.Dive) divemodel.Report {
+	var r divemodel.Report
+	drop := func(set bool, field, format string, args ...interface{}) {
+		if set {
+			r.Add(divemodel.ReportKind_Dropped, field, format, args...)
+		}
+	}
+
+	drop(m.Temperatures.MaxDepth != 0, "Temperatures.MaxDepth", "%.1f °C", m.Temperatures.MaxDepth)
+	drop(m.Location != (divemodel.Location{}), "Location", "%+v", m.Location)
+	drop(m.Conditions != (divemodel.Conditions{}), "Conditions", "%+v", m.Conditions)
+	drop(m.Partner != "", "Partner", "%q", m.Partner)
+	drop(m.Boat != "", "Boat", "%q", m.Boat)
+	drop(m.Weight != 0, "Weight", "%.1f kg", m.Weight)
+	drop(m.Notes != "", "Notes", "%q", m.Notes)
+
+	for i, c := range m.Cylinders {
+		c.Gas = divemodel.Gas{}
+		if i == 0 {
+			c.StartPressure, c.EndPressure = 0, 0
+		}
+		drop(c != (divemodel.Cylinder{}), fmt.Sprintf("Cylinders[%d]", i), "%+v", c)
+	}
+
+	var pressure, heartRate bool
+	for _, s := range m.Samples {
+		pressure = pressure || s.Pressure != 0
+		heartRate = heartRate || s.HeartRate != 0
+	}
+	drop(pressure, "Samples.Pressure", "tank pressure samples")
+	drop(heartRate, "Samples.HeartRate", "heart rate samples")
+
+	return r
+}
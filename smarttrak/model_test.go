@@ -0,0 +1,139 @@
+package smarttrak
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/octo/divelogs-go/divemodel"
+)
+
+func TestModel(t *testing.T) {
+	want, err := ReadDive(bytes.NewReader(testDiveData()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, report := ToModel(want)
+	if got, want := len(m.Cylinders), 2; got != want {
+		t.Errorf("len(Cylinders) = %d, want %d", got, want)
+	}
+	wantChanges := []divemodel.GasChange{{Time: 12 * time.Second, Cylinder: 1}}
+	if diff := cmp.Diff(wantChanges, m.GasChanges); diff != "" {
+		t.Errorf("GasChanges differ (-want/+got):\n%s", diff)
+	}
+	if got, want := m.Computer.ID, "0x00c0ffee"; got != want {
+		t.Errorf("Computer.ID = %q, want %q", got, want)
+	}
+
+	fields := map[string]divemodel.ReportKind{}
+	for _, e := range report {
+		fields[e.Field] = e.Kind
+	}
+	for field, kind := range map[string]divemodel.ReportKind{
+		"TimeLimit":       divemodel.ReportKind_Dropped,
+		"Events":          divemodel.ReportKind_Dropped,
+		"DecoTemperature": divemodel.ReportKind_Heuristic,
+		"SampleInterval":  divemodel.ReportKind_Heuristic,
+		"PressureStart":   divemodel.ReportKind_Heuristic,
+	} {
+		if fields[field] != kind {
+			t.Errorf("ToModel() report: %s is %v, want %v", field, fields[field], kind)
+		}
+	}
+
+	got, report := FromModel(m)
+	if len(report) != 0 {
+		t.Errorf("FromModel() report = %v, want empty", report)
+	}
+
+	// Only the start of flags is represented by events, and the model does
+	// not hold SmartTrak specific or unknown data.
+	for i := range want.Profile {
+		want.Profile[i].Alert = got.Profile[i].Alert
+		want.Profile[i].MBNoStopTime = 0
+	}
+	opts := []cmp.Option{
+		cmpopts.IgnoreUnexported(Dive{}),
+		cmpopts.IgnoreFields(Dive{}, "Events", "Settings", "TemperatureConfidence",
+			"WorkSensitivity", "DesatBefore", "TimeLimit", "DepthLimit", "TankWarning", "TankReserve", "Trailer"),
+	}
+	if diff := cmp.Diff(want, got, opts...); diff != "" {
+		t.Errorf("FromModel(ToModel()) differs (-want/+got):\n%s", diff)
+	}
+}
+
+func TestFromModel_NoStop(t *testing.T) {
+	m := &divemodel.Dive{
+		Time:           time.Date(2021, time.October, 17, 11, 15, 16, 0, time.UTC),
+		Duration:       time.Minute,
+		SampleInterval: 20 * time.Second,
+		Samples: []divemodel.Sample{
+			{Time: 0, Depth: 1},
+			{Time: 20 * time.Second, Depth: 2, NoStopTime: 30 * time.Minute},
+			{Time: 40 * time.Second, Depth: 1.5, NoStopTime: 30 * time.Minute},
+		},
+	}
+	d, _ := FromModel(m)
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.WriteHeader(&Header{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteDive(d); err != nil {
+		t.Fatal(err)
+	}
+	lb, err := ReadLogbook(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []time.Duration
+	for _, p := range lb.Dives[0].Profile {
+		got = append(got, p.NoStopTime)
+	}
+	want := []time.Duration{0, 30 * time.Minute, 30 * time.Minute}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("NoStopTime differs (-want/+got):\n%s", diff)
+	}
+}
+
+func TestToModel_Capabilities(t *testing.T) {
+	d, err := ReadDive(bytes.NewReader(testDiveData()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		model     string
+		percentHE int
+		want      []string
+	}{
+		{"", 0, nil},
+		{"Aladin Tec", 0, nil},
+		{"Aladin Tec", 30, []string{"Device"}},
+		{"Galileo Sol", 0, []string{"Profile.Pressure", "Profile.HeartRate"}},
+		{"Galileo Trimix", 30, []string{"Profile.Pressure", "Profile.HeartRate"}},
+	} {
+		d.Device, _ = ModelDevice(tc.model)
+		if tc.model == "" {
+			d.Device = Device{}
+		}
+		d.PercentHE = tc.percentHE
+
+		_, report := ToModel(d)
+		var got []string
+		for _, e := range report {
+			switch e.Field {
+			case "Profile.Pressure", "Profile.HeartRate", "Device":
+				got = append(got, e.Field)
+			}
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("ToModel() with model %q and %d%% He: report differs (-want/+got):\n%s", tc.model, tc.percentHE, diff)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package smarttrak
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// noStopSize is the payload size of no-stop blocks written by SmartTrak.
+const noStopSize = 2
+
+func isNoStop(e Event) bool {
+	return e.Type == BlockType_Extended && e.ExtendedType == ExtendedType_NoStop
+}
+
+// encodeNoStop updates the payload of a no-stop block with the no-stop times
+// of p. The returned slice is a copy; unknown bytes of payload are retained.
+func encodeNoStop(payload []byte, p DataPoint) ([]byte, error) {
+	ret := make([]byte, noStopSize)
+	if len(payload) > noStopSize {
+		ret = make([]byte, len(payload))
+	}
+	copy(ret, payload)
+
+	for i, d := range []time.Duration{p.NoStopTime, p.MBNoStopTime} {
+		minutes := math.Round(d.Minutes())
+		if minutes < 0 || minutes > math.MaxUint8 {
+			return nil, fmt.Errorf("no-stop time %v out of range", d)
+		}
+		ret[i] = byte(minutes)
+	}
+	return ret, nil
+}
+
+// noStopTimes returns the no-stop times of each data point in the profile, as
+// they would be decoded from the no-stop blocks in events.
+func (d *Dive) noStopTimes(events []Event) [][2]time.Duration {
+	var (
+		ret   = make([][2]time.Duration, len(d.Profile))
+		state [2]time.Duration
+		next  int
+	)
+	for i := range ret {
+		for ; next < len(events) && events[next].Index <= i; next++ {
+			if e := events[next]; isNoStop(e) && len(e.Payload) >= noStopSize {
+				state = [2]time.Duration{parseDurationMin(uint16(e.Payload[0])), parseDurationMin(uint16(e.Payload[1]))}
+			}
+		}
+		ret[i] = state
+	}
+	return ret
+}
+
+// noStopEventsForWriting returns events with the no-stop blocks updated to
+// match the NoStopTime and MBNoStopTime of the profile.
+//
+// If the no-stop blocks in events reproduce the profile's no-stop times, events
+// is returned unchanged. Otherwise the no-stop blocks are replaced by new ones
+// at each data point whose no-stop times differ from the previous one's.
+// Blocks following the last data point are kept.
+func (d *Dive) noStopEventsForWriting(events []Event) ([]Event, error) {
+	unchanged := true
+	for i, got := range d.noStopTimes(events) {
+		p := d.Profile[i]
+		unchanged = unchanged && got == [2]time.Duration{p.NoStopTime, p.MBNoStopTime}
+	}
+	if unchanged {
+		return events, nil
+	}
+
+	var (
+		ret      []Event
+		payloads [][]byte
+	)
+	for _, e := range events {
+		if isNoStop(e) && e.Index < len(d.Profile) {
+			payloads = append(payloads, e.Payload)
+			continue
+		}
+		ret = append(ret, e)
+	}
+
+	var prev DataPoint
+	for i, p := range d.Profile {
+		if p.NoStopTime == prev.NoStopTime && p.MBNoStopTime == prev.MBNoStopTime {
+			continue
+		}
+		prev = p
+
+		var orig []byte
+		if len(payloads) > 0 {
+			orig, payloads = payloads[0], payloads[1:]
+		}
+		payload, err := encodeNoStop(orig, p)
+		if err != nil {
+			return nil, fmt.Errorf("profile index %d: %w", i, err)
+		}
+		ret = insertEvent(ret, Event{
+			Index:        i,
+			Type:         BlockType_Extended,
+			ExtendedType: ExtendedType_NoStop,
+			Payload:      payload,
+		})
+	}
+	return ret, nil
+}
+
+// insertEvent inserts e after all events with the same or a lower Index.
+func insertEvent(events []Event, e Event) []Event {
+	pos := len(events)
+	for j, other := range events {
+		if other.Index > e.Index {
+			pos = j
+			break
+		}
+	}
+	return append(events[:pos], append([]Event{e}, events[pos:]...)...)
+}
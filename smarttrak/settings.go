@@ -0,0 +1,139 @@
+package smarttrak
+
+import "fmt"
+
+// Settings holds the configuration words stored with each dive.
+//
+// Only few bits have been identified so far. Bits that are set but whose
+// meaning is not known are returned by UnknownBits, to help with reverse
+// engineering the remaining ones.
+//
+// The dive computer settings and where they are stored:
+//
+//	water type            Settings1 bit 0x00100000, see WaterType
+//	ppO2 limit            record offset 60, probably, see Dive.PPO2Limit
+//	workload sensitivity  record offset 68, scale unknown, see Dive.WorkSensitivity
+//	altitude class        not located
+//	microbubble level     not located
+//	units                 not located
+//	PDIS / deep stop      not located
+//	safety stop timer     not located
+//
+// The settings that have not been located are not decoded, because there are
+// no dive records recorded with known, differing values of these settings to
+// compare. Given such files, "correlate-asd -size 1" lists the record bytes
+// and UnknownBits the settings bits that differ between them. Typed accessors
+// for these settings are still to be added once they have been located.
+type Settings struct {
+	FeatureSet uint32
+	Settings1  uint32
+	Settings2  uint32
+}
+
+// SettingsWord identifies one of the words in Settings.
+type SettingsWord int
+
+const (
+	SettingsWord_FeatureSet SettingsWord = iota
+	SettingsWord_Settings1
+	SettingsWord_Settings2
+)
+
+func (w SettingsWord) String() string {
+	switch w {
+	case SettingsWord_FeatureSet:
+		return "featureSet"
+	case SettingsWord_Settings1:
+		return "settings1"
+	case SettingsWord_Settings2:
+		return "settings2"
+	}
+	return fmt.Sprintf("SettingsWord(%d)", int(w))
+}
+
+// SettingsBit is a single bit in one of the settings words.
+type SettingsBit struct {
+	Word SettingsWord
+	Mask uint32
+}
+
+func (b SettingsBit) String() string {
+	return fmt.Sprintf("%v&%#08x", b.Word, b.Mask)
+}
+
+var bitSaltWater = SettingsBit{SettingsWord_Settings1, 0x00100000}
+
+// knownBits maps the bits that have been identified to their meaning.
+var knownBits = map[SettingsBit]string{
+	bitSaltWater: "salt water",
+}
+
+// IsSet reports whether bit b is set.
+func (s Settings) IsSet(b SettingsBit) bool {
+	return s.word(b.Word)&b.Mask != 0
+}
+
+// WaterType returns the water type the device was configured for.
+func (s Settings) WaterType() WaterType {
+	if s.IsSet(bitSaltWater) {
+		return WaterType_Salt
+	}
+	return WaterType_Sweet
+}
+
+func (s Settings) withWaterType(wt WaterType) Settings {
+	if wt == WaterType_Salt {
+		s.Settings1 |= bitSaltWater.Mask
+	} else {
+		s.Settings1 &^= bitSaltWater.Mask
+	}
+	return s
+}
+
+// Known returns the meaning of all identified bits that are set.
+func (s Settings) Known() []string {
+	var ret []string
+	for _, b := range s.bits() {
+		if name, ok := knownBits[b]; ok {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// UnknownBits returns all bits that are set but have not been identified.
+func (s Settings) UnknownBits() []SettingsBit {
+	var ret []SettingsBit
+	for _, b := range s.bits() {
+		if _, ok := knownBits[b]; !ok {
+			ret = append(ret, b)
+		}
+	}
+	return ret
+}
+
+// bits returns all set bits, ordered by word and mask.
+func (s Settings) bits() []SettingsBit {
+	var ret []SettingsBit
+	for _, w := range []SettingsWord{SettingsWord_FeatureSet, SettingsWord_Settings1, SettingsWord_Settings2} {
+		v := s.word(w)
+		for i := 0; i < 32; i++ {
+			if mask := uint32(1) << i; v&mask != 0 {
+				ret = append(ret, SettingsBit{w, mask})
+			}
+		}
+	}
+	return ret
+}
+
+func (s Settings) word(w SettingsWord) uint32 {
+	switch w {
+	case SettingsWord_FeatureSet:
+		return s.FeatureSet
+	case SettingsWord_Settings1:
+		return s.Settings1
+	case SettingsWord_Settings2:
+		return s.Settings2
+	}
+	return 0
+}
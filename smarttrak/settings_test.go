@@ -0,0 +1,58 @@
+package smarttrak
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSettings(t *testing.T) {
+	s := Settings{
+		FeatureSet: 0x00000081,
+		Settings1:  0x00100002,
+	}
+
+	if got, want := s.WaterType(), WaterType_Salt; got != want {
+		t.Errorf("WaterType() = %v, want %v", got, want)
+	}
+	if diff := cmp.Diff([]string{"salt water"}, s.Known()); diff != "" {
+		t.Errorf("Known() differs (-want/+got):\n%s", diff)
+	}
+
+	want := []SettingsBit{
+		{SettingsWord_FeatureSet, 0x01},
+		{SettingsWord_FeatureSet, 0x80},
+		{SettingsWord_Settings1, 0x02},
+	}
+	if diff := cmp.Diff(want, s.UnknownBits()); diff != "" {
+		t.Errorf("UnknownBits() differs (-want/+got):\n%s", diff)
+	}
+}
+
+func TestReadDive_Settings(t *testing.T) {
+	data := testDiveData()
+	binary.LittleEndian.PutUint16(data[60:], 1400)
+	binary.LittleEndian.PutUint16(data[68:], 3)
+
+	d, err := ReadDive(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := d.PPO2Limit, 1.4; got != want {
+		t.Errorf("PPO2Limit = %g, want %g", got, want)
+	}
+	if got, want := d.WorkSensitivity, uint16(3); got != want {
+		t.Errorf("WorkSensitivity = %d, want %d", got, want)
+	}
+
+	d.PPO2Limit = 1.6
+	var buf bytes.Buffer
+	if err := NewWriter(&buf).WriteDive(d); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := binary.LittleEndian.Uint16(buf.Bytes()[60:]), uint16(1600); got != want {
+		t.Errorf("WriteDive() wrote %d at offset 60, want %d", got, want)
+	}
+}
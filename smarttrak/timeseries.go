@@ -0,0 +1,354 @@
+package smarttrak
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// timeseries holds the state of the timeseries parser. The state is kept
+// across profile segments (0xFA blocks), so that a profile interrupted by
+// other blocks continues where it left off.
+type timeseries struct {
+	dive  *Dive
+	state DataPoint
+
+	// Temperature is recorded relatively, i.e. in changes to the previous temperature.
+	// Unfortunately, I have been unable to identify a "start temperature" in the binary data.
+	// We record the up and down steps in the data, and later use the
+	// MinTemperature and MaxTemperature fields to scale the fields correctly,
+	// see scaleTemperatures.
+	minTemp, maxTemp, currTemp int
+
+	// depth is the current depth in profile units, see parseProfileDepth.
+	depth int
+}
+
+// fixedBlockSize is the payload size of blocks with a fixed size.
+var fixedBlockSize = map[BlockType]int{
+	BlockType_F0: 1,
+	BlockType_F2: 1,
+	BlockType_F3: 2,
+	BlockType_F4: 2,
+	BlockType_F8: 4,
+	BlockType_F9: 1,
+}
+
+func (d *Dive) parseTimeseriesBlock(data []byte) error {
+	ts := &timeseries{
+		dive: d,
+	}
+
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+
+		switch {
+		case fixedBlockSize[BlockType(b)] != 0:
+			size := fixedBlockSize[BlockType(b)]
+			p, err := payload(data, i, size)
+			if err != nil {
+				return err
+			}
+			d.addEvent(i, BlockType(b), 0, p)
+			i += size
+		case b == 0xfa:
+			i = ts.parseProfile(data, i+1) - 1
+		case b == 0xfb:
+			p, err := payload(data, i, 2)
+			if err != nil {
+				return err
+			}
+			size, typ := int(p[0]), int(p[1])
+			if size < 2 {
+				return &ParseError{
+					Offset: i,
+					Block:  BlockType_Extended,
+					Want:   2,
+					Got:    size,
+					Err:    errors.New("invalid block length"),
+				}
+			}
+
+			p, err = payload(data, i, size)
+			if err != nil {
+				return err
+			}
+			p = p[2:]
+
+			if err := ts.parseExtended(i, typ, p); err != nil {
+				return err
+			}
+			d.addEvent(i, BlockType_Extended, typ, p)
+			i += size
+		default:
+			return &ParseError{
+				Offset: i,
+				Block:  BlockType(b),
+				Err:    fmt.Errorf("unexpected byte %#x", b),
+			}
+		}
+	}
+
+	ts.scaleTemperatures()
+	d.setSampleTimes(detectSampleInterval(d.Duration, len(d.Profile)))
+	return nil
+}
+
+// sampleIntervals are the profile sample intervals supported by the dive
+// computers.
+var sampleIntervals = []time.Duration{
+	1 * time.Second,
+	2 * time.Second,
+	4 * time.Second,
+	10 * time.Second,
+	20 * time.Second,
+	30 * time.Second,
+	60 * time.Second,
+}
+
+const defaultSampleInterval = 4 * time.Second
+
+// detectSampleInterval returns the supported sample interval closest to
+// duration / samples. The interval is not stored in the dive record, or at
+// least it has not been found yet, so it is only inferred if the Device of
+// the dive does not specify it.
+func detectSampleInterval(duration time.Duration, samples int) time.Duration {
+	if duration <= 0 || samples == 0 {
+		return defaultSampleInterval
+	}
+	estimate := duration.Seconds() / float64(samples)
+
+	ret := defaultSampleInterval
+	minDist := math.Inf(1)
+	for _, interval := range sampleIntervals {
+		// Compare ratios rather than differences, so that e.g. 3s is
+		// closer to 2s than to 4s.
+		if dist := math.Abs(math.Log(estimate / interval.Seconds())); dist < minDist {
+			ret, minDist = interval, dist
+		}
+	}
+	return ret
+}
+
+// readSampleInterval returns the sample interval the dive is read with after
+// writing it, if its dive computer is registered as d.Device.
+func (d *Dive) readSampleInterval() time.Duration {
+	if d.Device.SampleInterval != 0 {
+		return d.Device.SampleInterval
+	}
+	duration := parseDurationMin(uint16(encodeDurationMin(d.Duration)))
+	return detectSampleInterval(duration, len(d.Profile))
+}
+
+// setSampleTimes sets SampleInterval and the time of each data point and
+// gas switch.
+func (d *Dive) setSampleTimes(interval time.Duration) {
+	d.SampleInterval = interval
+
+	for i := range d.Profile {
+		d.Profile[i].Time = d.Time.Add(time.Duration(i) * d.SampleInterval)
+	}
+	for i := range d.GasMixes {
+		d.GasMixes[i].Time = d.Time.Add(time.Duration(d.GasMixes[i].Index) * d.SampleInterval)
+	}
+}
+
+// minExtendedSize is the minimum payload size of extended blocks that are
+// decoded.
+var minExtendedSize = map[int]int{
+	ExtendedType_NoStop:  2,
+	ExtendedType_Mixture: 4,
+}
+
+// parseExtended decodes the payload of the 0xFB block at data[offset].
+func (ts *timeseries) parseExtended(offset, typ int, p []byte) error {
+	if got, want := len(p), minExtendedSize[typ]; got < want {
+		return &ParseError{
+			Offset: offset,
+			Block:  BlockType_Extended,
+			Want:   want,
+			Got:    got,
+			Err:    fmt.Errorf("extended block type %d too short", typ),
+		}
+	}
+
+	switch typ {
+	case ExtendedType_NoStop:
+		ts.state.NoStopTime = parseDurationMin(uint16(p[0]))
+		ts.state.MBNoStopTime = parseDurationMin(uint16(p[1]))
+	case ExtendedType_Mixture:
+		d := ts.dive
+		mix := parseGasMix(p)
+		mix.Index = len(d.Profile)
+		d.GasMixes = append(d.GasMixes, mix)
+		if len(d.GasMixes) == 1 {
+			d.PercentO2 = mix.PercentO2
+			d.PercentHE = mix.PercentHE
+		}
+	}
+	return nil
+}
+
+// payload returns the n bytes following the control byte at data[i].
+func payload(data []byte, i, n int) ([]byte, error) {
+	if got := len(data) - i - 1; got < n {
+		return nil, &ParseError{
+			Offset: i,
+			Block:  BlockType(data[i]),
+			Want:   n,
+			Got:    got,
+			Err:    io.ErrUnexpectedEOF,
+		}
+	}
+	return data[i+1 : i+1+n], nil
+}
+
+func (d *Dive) addEvent(offset int, typ BlockType, extType int, payload []byte) {
+	d.Events = append(d.Events, Event{
+		Offset:       offset,
+		Index:        len(d.Profile),
+		Type:         typ,
+		ExtendedType: extType,
+		Payload:      append([]byte(nil), payload...),
+	})
+}
+
+// parseProfile parses the profile data starting at data[start]. It returns
+// the position of the 0xFB byte terminating the profile data, or len(data).
+func (ts *timeseries) parseProfile(data []byte, start int) int {
+	d := ts.dive
+	for i := start; i < len(data); i++ {
+		b := data[i]
+
+		switch {
+		case b&0xf0 == 0xb0:
+			raw := uint8(b & 0x0f)
+			if raw&0x08 != 0 {
+				raw |= 0xf0
+			}
+			ts.currTemp += int(int8(raw))
+			if ts.minTemp > ts.currTemp {
+				ts.minTemp = ts.currTemp
+			}
+			if ts.maxTemp < ts.currTemp {
+				ts.maxTemp = ts.currTemp
+			}
+
+			ts.state.Temperature = float64(ts.currTemp)
+		case b&0xf0 == 0xc0:
+			n := int(b & 0x0f)
+			for i := 0; i < n; i++ {
+				d.Profile = append(d.Profile, ts.state)
+			}
+		case b&0xf0 == 0xe0:
+			if b&0x02 != 0 {
+				ts.state.Alert = true
+			}
+			if b&0x01 != 0 {
+				ts.state.Warning = true
+			}
+			if b&0x04 != 0 {
+				ts.state.HighWorkload = true
+			}
+			if b&0x08 != 0 {
+				ts.state.Bookmark = true
+			}
+			if b&0x0f == 0 {
+				ts.state.Alert = false
+				ts.state.Warning = false
+				ts.state.HighWorkload = false
+				ts.state.Bookmark = false
+			}
+		case b == 0xfb:
+			return i
+		case b&0x80 != 0:
+			d.addEvent(i, BlockType(b), 0, nil)
+		default:
+			ts.depth += parseDepthDiff(b)
+
+			ts.state.Depth = parseProfileDepth(ts.depth, d.WaterType)
+			d.Profile = append(d.Profile, ts.state)
+		}
+	}
+
+	return len(data)
+}
+
+// defaultTempStep is the assumed size of a temperature step in degrees
+// Celsius, used when the step size cannot be derived from the dive record.
+const defaultTempStep = 0.1
+
+// scaleTemperatures converts the relative temperature steps to degrees
+// Celsius and sets the dive's TemperatureConfidence.
+//
+// If both the profile and the dive record show a temperature range, the steps
+// are scaled to MinTemperature..MaxTemperature. Otherwise the lowest
+// temperature of the profile is anchored at the best available temperature
+// from the dive record, see temperatureAnchor, and defaultTempStep is used as
+// the step size.
+func (ts *timeseries) scaleTemperatures() {
+	d := ts.dive
+
+	if ts.minTemp != ts.maxTemp && d.MinTemperature != d.MaxTemperature {
+		d.tempScale = tempScale{
+			base:   d.MinTemperature,
+			factor: (d.MaxTemperature - d.MinTemperature) / float64(ts.maxTemp-ts.minTemp),
+			offset: float64(ts.minTemp),
+		}
+		d.TemperatureConfidence = TemperatureConfidence_High
+	} else {
+		base, conf := d.temperatureAnchor()
+		d.tempScale = tempScale{
+			base:   base,
+			factor: defaultTempStep,
+			offset: float64(ts.minTemp),
+		}
+		if ts.minTemp != ts.maxTemp && conf > TemperatureConfidence_Low {
+			// The step size is assumed.
+			conf = TemperatureConfidence_Low
+		}
+		d.TemperatureConfidence = conf
+	}
+
+	if len(d.Profile) == 0 {
+		d.TemperatureConfidence = TemperatureConfidence_None
+	}
+	for i := range d.Profile {
+		d.Profile[i].Temperature = d.tempScale.celsius(d.Profile[i].Temperature)
+	}
+}
+
+// temperatureAnchor returns the temperature the lowest profile temperature is
+// anchored at, if the profile does not show a temperature range.
+//
+// MinTemperature is preferred. A MinTemperature and MaxTemperature of zero are
+// taken to mean that the dive computer did not record a temperature, in which
+// case DecoTemperature and AirTemperature are used, in that order.
+func (d *Dive) temperatureAnchor() (float64, TemperatureConfidence) {
+	switch {
+	case d.MinTemperature == d.MaxTemperature && d.MinTemperature != 0:
+		return d.MinTemperature, TemperatureConfidence_High
+	case d.MinTemperature != 0 || d.MaxTemperature != 0:
+		return d.MinTemperature, TemperatureConfidence_Low
+	case d.DecoTemperature != 0:
+		return d.DecoTemperature, TemperatureConfidence_Low
+	case d.AirTemperature != 0:
+		return d.AirTemperature, TemperatureConfidence_Low
+	}
+	return 0, TemperatureConfidence_None
+}
+
+// tempScale converts relative temperature steps to degrees Celsius.
+type tempScale struct {
+	base, factor, offset float64
+}
+
+func (s tempScale) celsius(steps float64) float64 {
+	return s.base + (s.factor * (steps - s.offset))
+}
+
+func (s tempScale) steps(celsius float64) int {
+	return int(math.Round((celsius-s.base)/s.factor + s.offset))
+}
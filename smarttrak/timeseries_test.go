@@ -0,0 +1,77 @@
+package smarttrak
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestDetectSampleInterval(t *testing.T) {
+	cases := []struct {
+		duration time.Duration
+		samples  int
+		want     time.Duration
+	}{
+		{30 * time.Minute, 450, 4 * time.Second},
+		{30 * time.Minute, 1800, 1 * time.Second},
+		{30 * time.Minute, 900, 2 * time.Second},
+		{30 * time.Minute, 180, 10 * time.Second},
+		// Duration is rounded to full minutes.
+		{31 * time.Minute, 450, 4 * time.Second},
+		{10 * time.Minute, 290, 2 * time.Second},
+		{0, 100, 4 * time.Second},
+		{10 * time.Minute, 0, 4 * time.Second},
+	}
+
+	for _, tc := range cases {
+		if got := detectSampleInterval(tc.duration, tc.samples); got != tc.want {
+			t.Errorf("detectSampleInterval(%v, %d) = %v, want %v", tc.duration, tc.samples, got, tc.want)
+		}
+	}
+}
+
+func TestScaleTemperatures(t *testing.T) {
+	var (
+		constant = []byte{0xFA, 0x00, 0x00}
+		rising   = []byte{0xFA, 0x00, 0xB2, 0x00}
+	)
+
+	cases := []struct {
+		name     string
+		dive     Dive
+		data     []byte
+		want     []float64
+		wantConf TemperatureConfidence
+	}{
+		{"range", Dive{MinTemperature: 10, MaxTemperature: 12}, rising, []float64{10, 12}, TemperatureConfidence_High},
+		{"constant", Dive{MinTemperature: 8.8, MaxTemperature: 8.8}, constant, []float64{8.8, 8.8}, TemperatureConfidence_High},
+		{"constant without range", Dive{MinTemperature: 8.8, MaxTemperature: 9.5}, constant, []float64{8.8, 8.8}, TemperatureConfidence_Low},
+		{"assumed step size", Dive{MinTemperature: 10, MaxTemperature: 10}, rising, []float64{10, 10.2}, TemperatureConfidence_Low},
+		{"deco temperature", Dive{DecoTemperature: 9, AirTemperature: 20}, constant, []float64{9, 9}, TemperatureConfidence_Low},
+		{"air temperature", Dive{AirTemperature: 20}, constant, []float64{20, 20}, TemperatureConfidence_Low},
+		{"no temperature", Dive{}, constant, []float64{0, 0}, TemperatureConfidence_None},
+		{"no profile", Dive{MinTemperature: 10, MaxTemperature: 12}, nil, nil, TemperatureConfidence_None},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := tc.dive
+			if err := d.parseTimeseriesBlock(tc.data); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []float64
+			for _, p := range d.Profile {
+				got = append(got, p.Temperature)
+			}
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateApprox(0, 0.001)); diff != "" {
+				t.Errorf("temperatures differ (-want/+got):\n%s", diff)
+			}
+			if d.TemperatureConfidence != tc.wantConf {
+				t.Errorf("TemperatureConfidence = %v, want %v", d.TemperatureConfidence, tc.wantConf)
+			}
+		})
+	}
+}
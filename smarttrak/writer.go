@@ -0,0 +1,394 @@
+package smarttrak
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+)
+
+// Writer writes SmartTrak .asd files.
+//
+// Fields that are not understood yet are written back as they were read. If
+// the profile and the events of a dive have not been modified, the original
+// timeseries data is written, so that unmodified dives are reproduced byte for
+// byte.
+type Writer struct {
+	w      io.Writer
+	layout layout
+}
+
+// NewWriter returns a new Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w:      w,
+		layout: defaultLayout,
+	}
+}
+
+// WriteLogbook writes the header and all dives of lb to w.
+func WriteLogbook(w io.Writer, lb *Logbook) error {
+	wr := NewWriter(w)
+	if err := wr.WriteHeader(lb.Header); err != nil {
+		return err
+	}
+	for i, d := range lb.Dives {
+		if err := wr.WriteDive(d); err != nil {
+			return fmt.Errorf("writing dive #%d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// WriteHeader writes the file header. It must be called once, before the
+// first call to WriteDive. The dives are written in the format of h.Version,
+// which defaults to 7.
+func (w *Writer) WriteHeader(h *Header) error {
+	version, className := h.Version, h.ClassName
+	if version == 0 {
+		version = defaultVersion
+	}
+
+	l, err := layoutFor(version)
+	if err != nil {
+		return err
+	}
+	w.layout = l
+	if className == "" {
+		className = l.className
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, []uint16{uint16(version), uint16(len(className))})
+	buf.WriteString(className)
+
+	for i, f := range []struct {
+		str     string
+		unknown []byte
+	}{
+		{h.Name, h.Unknown1},
+		{h.SuitType, h.Unknown2},
+		{h.Weather, h.Unknown3},
+	} {
+		if err := writeString(&buf, f.str); err != nil {
+			return err
+		}
+		buf.Write(orZero(f.unknown, l.headerUnknown[i]))
+	}
+
+	_, err = w.w.Write(buf.Bytes())
+	return err
+}
+
+// WriteDive writes a single dive. A *SampleIntervalError is returned if the
+// dive's SampleInterval would not be read back, see Device.SampleInterval.
+func (w *Writer) WriteDive(d *Dive) error {
+	if read := d.readSampleInterval(); d.SampleInterval != 0 && len(d.Profile) != 0 && d.SampleInterval != read {
+		return &SampleIntervalError{
+			SampleInterval: d.SampleInterval,
+			Read:           read,
+		}
+	}
+
+	ts, err := d.encodeTimeseries()
+	if err != nil {
+		return err
+	}
+	if len(ts) > math.MaxUint16 {
+		return fmt.Errorf("timeseries data too large: %d bytes", len(ts))
+	}
+
+	rec, err := d.encodeRecord(w.layout.recordSize, len(ts))
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(rec)
+	buf.Write(ts)
+	buf.Write(orZero(d.Trailer, w.layout.trailerSize))
+
+	_, err = w.w.Write(buf.Bytes())
+	return err
+}
+
+// orZero returns data if it has the given size, and size zero bytes otherwise.
+func orZero(data []byte, size int) []byte {
+	if len(data) == size {
+		return data
+	}
+	return make([]byte, size)
+}
+
+// recordWriter writes fields into the fixed size dive record. The first error
+// is kept in err.
+type recordWriter struct {
+	data []byte
+	err  error
+}
+
+func (w *recordWriter) uint16(offset int, v int64, field string) {
+	if v < 0 || v > math.MaxUint16 {
+		w.setErr(fmt.Errorf("%s out of range: %d", field, v))
+		return
+	}
+	binary.LittleEndian.PutUint16(w.data[offset:], uint16(v))
+}
+
+func (w *recordWriter) int16(offset int, v int64, field string) {
+	if v < math.MinInt16 || v > math.MaxInt16 {
+		w.setErr(fmt.Errorf("%s out of range: %d", field, v))
+		return
+	}
+	binary.LittleEndian.PutUint16(w.data[offset:], uint16(int16(v)))
+}
+
+func (w *recordWriter) uint32(offset int, v uint32) {
+	binary.LittleEndian.PutUint32(w.data[offset:], v)
+}
+
+func (w *recordWriter) setErr(err error) {
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+func (d *Dive) encodeRecord(recordSize, timeseriesSize int) ([]byte, error) {
+	w := &recordWriter{
+		data: make([]byte, recordSize),
+	}
+	copy(w.data, d.record)
+
+	// Keep the original time if it is unchanged, to preserve the half
+	// second not represented in Dive.Time.
+	if orig := parseTime(w.data[16:]); d.record == nil || !sameTime(orig, d.Time) {
+		if err := encodeTime(w.data[16:], d.Time); err != nil {
+			return nil, err
+		}
+	}
+
+	wt := d.WaterType
+	w.uint32(8, d.DeviceID)
+	w.uint16(28, int64(d.Sequence), "Sequence")
+	w.int16(30, encodeTemperature(d.AirTemperature), "AirTemperature")
+	w.uint16(33, encodeDurationMin(d.TimeLimit), "TimeLimit")
+	w.uint32(35, d.Settings.FeatureSet)
+	w.uint16(42, encodeDepth(d.MaxDepth, wt), "MaxDepth")
+	w.uint16(44, encodeDurationMin(d.Duration), "Duration")
+	w.int16(46, encodeTemperature(d.MinTemperature), "MinTemperature")
+	w.uint16(50, encodeDurationMin(d.SurfaceInterval), "SurfaceInterval")
+	w.uint16(54, encodePressure(d.PressureStart), "PressureStart")
+	w.uint16(56, encodePressure(d.PressureEnd), "PressureEnd")
+	w.uint16(60, int64(math.Round(d.PPO2Limit*1000.0)), "PPO2Limit")
+	w.uint16(62, encodeDepth(d.DepthLimit, wt), "DepthLimit")
+	w.uint16(64, encodePressure(d.TankWarning), "TankWarning")
+	w.uint16(66, encodePressure(d.TankReserve), "TankReserve")
+	w.uint16(68, int64(d.WorkSensitivity), "WorkSensitivity")
+	w.int16(70, encodeTemperature(d.DecoTemperature), "DecoTemperature")
+	w.uint16(72, int64(d.DesatBefore), "DesatBefore")
+	w.uint32(82, d.Settings.withWaterType(wt).Settings1)
+	w.uint16(158, encodeDepth(d.AverageDepth, wt), "AverageDepth")
+	w.int16(160, encodeTemperature(d.MaxTemperature), "MaxTemperature")
+	w.uint32(167, d.Settings.Settings2)
+	w.uint16(191, int64(timeseriesSize), "timeseries size")
+
+	return w.data, w.err
+}
+
+func sameTime(a, b time.Time) bool {
+	_, aOff := a.Zone()
+	_, bOff := b.Zone()
+	return a.Equal(b) && aOff == bOff
+}
+
+func encodeTime(data []byte, t time.Time) error {
+	_, offset := t.Zone()
+	if offset%900 != 0 {
+		return fmt.Errorf("time zone offset of %v is not a multiple of 15 minutes", t)
+	}
+
+	halfSec := 2 * (t.Unix() - timeOffset)
+	if halfSec < 0 {
+		return fmt.Errorf("time %v is before the SmartTrak epoch", t)
+	}
+
+	binary.LittleEndian.PutUint64(data[0:8], uint64(halfSec))
+	binary.LittleEndian.PutUint16(data[8:10], uint16(int16(offset/900)))
+	return nil
+}
+
+func encodeDurationMin(d time.Duration) int64 {
+	return int64(math.Round(d.Minutes()))
+}
+
+func encodeTemperature(t float64) int64 {
+	return int64(math.Round(t * 10.0))
+}
+
+func encodePressure(p float64) int64 {
+	return int64(math.Round(p * 128.0))
+}
+
+func encodeDepth(depth float64, wt WaterType) int64 {
+	return int64(math.Round(depth * wt.Density() / 10.0))
+}
+
+func encodeProfileDepth(depth float64, wt WaterType) int64 {
+	return int64(math.Round(depth * wt.Density() / 20.0))
+}
+
+// encodeTimeseries returns the timeseries data of the dive.
+func (d *Dive) encodeTimeseries() ([]byte, error) {
+	if d.timeseries != nil && d.timeseriesUnchanged() {
+		return d.timeseries, nil
+	}
+
+	events, err := d.noStopEventsForWriting(d.eventsForWriting())
+	if err != nil {
+		return nil, err
+	}
+	scale := d.tempScale
+	if scale.factor == 0 {
+		scale = tempScale{
+			base:   d.MinTemperature,
+			factor: 0.1,
+		}
+	}
+
+	var (
+		buf       []byte
+		inProfile bool
+		flags     uint8
+		tempSteps int
+		depth     int64
+		next      int
+	)
+
+	writeEvents := func(index int) error {
+		for ; next < len(events) && events[next].Index <= index; next++ {
+			e := events[next]
+			switch {
+			case e.Type == BlockType_Extended:
+				if len(e.Payload)+2 > math.MaxUint8 {
+					return fmt.Errorf("event %v: payload too large", e)
+				}
+				buf = append(buf, byte(e.Type), byte(len(e.Payload)+2), byte(e.ExtendedType))
+				buf = append(buf, e.Payload...)
+				// 0xFB terminates the profile data.
+				inProfile = false
+			case len(e.Payload) == 0 && isUnknownControlByte(byte(e.Type)):
+				if !inProfile {
+					buf = append(buf, byte(BlockType_Profile))
+					inProfile = true
+				}
+				buf = append(buf, byte(e.Type))
+			case len(e.Payload) == fixedBlockSize[e.Type] && !inProfile:
+				buf = append(buf, byte(e.Type))
+				buf = append(buf, e.Payload...)
+			default:
+				return fmt.Errorf("event %v cannot be encoded at profile index %d", e, e.Index)
+			}
+		}
+		return nil
+	}
+
+	for i, p := range d.Profile {
+		if err := writeEvents(i); err != nil {
+			return nil, err
+		}
+		if !inProfile {
+			buf = append(buf, byte(BlockType_Profile))
+			inProfile = true
+		}
+
+		want := p.flags()
+		if flags&^want != 0 {
+			buf = append(buf, 0xE0)
+			flags = 0
+		}
+		if want&^flags != 0 {
+			buf = append(buf, 0xE0|want)
+			flags = want
+		}
+
+		for steps := scale.steps(p.Temperature); steps != tempSteps; {
+			delta := steps - tempSteps
+			if delta < -8 {
+				delta = -8
+			} else if delta > 7 {
+				delta = 7
+			}
+			buf = append(buf, 0xB0|(byte(delta)&0x0F))
+			tempSteps += delta
+		}
+
+		raw := encodeProfileDepth(p.Depth, d.WaterType)
+		diff := raw - depth
+		if diff < -64 || diff > 63 {
+			return nil, fmt.Errorf("profile index %d: depth change of %.2fm cannot be encoded",
+				i, parseProfileDepth(int(diff), d.WaterType))
+		}
+		buf = append(buf, byte(diff)&0x7F)
+		depth = raw
+	}
+
+	if err := writeEvents(math.MaxInt); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// timeseriesUnchanged reports whether parsing the original timeseries data
+// yields the dive's current profile and events.
+func (d *Dive) timeseriesUnchanged() bool {
+	orig := *d
+	orig.Profile = nil
+	orig.Events = nil
+	orig.GasMixes = nil
+	orig.PercentO2 = 0
+	orig.PercentHE = 0
+
+	if err := orig.parseTimeseriesBlock(d.timeseries); err != nil {
+		return false
+	}
+	orig.setSampleTimes(d.SampleInterval)
+
+	return reflect.DeepEqual(orig.Profile, d.Profile) &&
+		reflect.DeepEqual(orig.Events, d.Events) &&
+		reflect.DeepEqual(orig.GasMixes, d.GasMixes) &&
+		orig.PercentO2 == d.PercentO2 &&
+		orig.PercentHE == d.PercentHE
+}
+
+// isUnknownControlByte reports whether b is reported as an event with an
+// empty payload when found in the profile data.
+func isUnknownControlByte(b byte) bool {
+	switch {
+	case b&0x80 == 0:
+		return false
+	case b&0xf0 == 0xb0, b&0xf0 == 0xc0, b&0xf0 == 0xe0, b == 0xfb:
+		return false
+	}
+	return true
+}
+
+func (d DataPoint) flags() uint8 {
+	var f uint8
+	if d.Warning {
+		f |= 0x01
+	}
+	if d.Alert {
+		f |= 0x02
+	}
+	if d.HighWorkload {
+		f |= 0x04
+	}
+	if d.Bookmark {
+		f |= 0x08
+	}
+	return f
+}
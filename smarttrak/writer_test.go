@@ -0,0 +1,293 @@
+package smarttrak
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func testFileData() []byte {
+	var b bytes.Buffer
+	b.Write(testHeaderData())
+	b.Write(testDiveData())
+	b.Write(testDiveData())
+	return b.Bytes()
+}
+
+func roundTrip(t *testing.T, lb *Logbook) *Logbook {
+	t.Helper()
+	return roundTripRegistry(t, lb, nil)
+}
+
+// roundTripRegistry is like roundTrip, but identifies the dive computers with
+// registry when reading.
+func roundTripRegistry(t *testing.T, lb *Logbook, registry *Registry) *Logbook {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := WriteLogbook(&buf, lb); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReader(&buf)
+	r.Registry = registry
+	got, err := r.ReadLogbook()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+var cmpOpts = []cmp.Option{
+	cmpopts.IgnoreUnexported(Header{}, Dive{}),
+	cmpopts.EquateApprox(0, 1e-9),
+}
+
+func TestWriter(t *testing.T) {
+	file := testFileData()
+	want, err := ReadLogbook(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteLogbook(&buf, want); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), file) {
+		t.Errorf("WriteLogbook() did not reproduce the input:\ngot  % X\nwant % X", buf.Bytes(), file)
+	}
+
+	got := roundTrip(t, want)
+	if diff := cmp.Diff(want, got, cmpOpts...); diff != "" {
+		t.Errorf("round trip: results differ (-want/+got):\n%s", diff)
+	}
+}
+
+func TestWriter_Modified(t *testing.T) {
+	want, err := ReadLogbook(bytes.NewReader(testFileData()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fix the device clock and modify the profile, forcing the timeseries
+	// data to be re-encoded.
+	d := want.Dives[0]
+	d.Time = d.Time.Add(time.Hour)
+	for i := range d.Profile {
+		d.Profile[i].Time = d.Profile[i].Time.Add(time.Hour)
+	}
+	for i := range d.GasMixes {
+		d.GasMixes[i].Time = d.GasMixes[i].Time.Add(time.Hour)
+	}
+	d.Profile[3].Depth += 0.5
+	d.Profile[5].Bookmark = true
+	d.PercentO2 = 32
+	d.GasMixes[0].PercentO2 = 32
+	d.Events[3].Payload = append([]byte{32}, d.Events[3].Payload[1:]...)
+	d.Trailer = []byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+	got := roundTrip(t, want)
+	if diff := cmp.Diff(want, got, append(cmpOpts, cmpopts.IgnoreFields(Event{}, "Offset"))...); diff != "" {
+		t.Errorf("round trip: results differ (-want/+got):\n%s", diff)
+	}
+}
+
+func TestWriter_New(t *testing.T) {
+	start := time.Date(2022, time.May, 1, 10, 0, 0, 0, time.FixedZone("Device/Local", 3*3600))
+	// The sample interval is not stored in the file. Five samples in one
+	// minute would be read as a 10s interval, so the dive computer is
+	// registered with its 4s interval.
+	device := Device{Model: "Galileo Sol", SampleInterval: 4 * time.Second}
+	registry := NewRegistry()
+	registry.AddDevice(0x1234, device)
+
+	d := &Dive{
+		DeviceID:        0x1234,
+		Device:          device,
+		Sequence:        1,
+		Time:            start,
+		Duration:        time.Minute,
+		SampleInterval:  4 * time.Second,
+		WaterType:       WaterType_Salt,
+		Settings:        Settings{Settings1: 0x00100000},
+		MaxDepth:        2.0,
+		AverageDepth:    0.8,
+		MinTemperature:  20.0,
+		MaxTemperature:  20.5,
+		AirTemperature:  25.0,
+		DecoTemperature: 20.5,
+		PercentO2:       32,
+	}
+	for i, depth := range []float64{0.5, 1.0, 2.0, 1.5, 0.5} {
+		d.Profile = append(d.Profile, DataPoint{
+			Time:        start.Add(time.Duration(i) * 4 * time.Second),
+			Depth:       depth,
+			Temperature: 20.5 - 0.1*float64(i),
+			Warning:     i == 2,
+		})
+	}
+
+	want := &Logbook{
+		Header: &Header{
+			Name:     "New logbook",
+			SuitType: "Wetsuit",
+			Weather:  "Sunny",
+		},
+		Dives: []*Dive{d},
+	}
+
+	got := roundTripRegistry(t, want, registry)
+
+	// The mix is recorded as a mixture block.
+	d.GasMixes = []GasMix{{Time: start, PercentO2: 32}}
+	// The temperatures span MinTemperature..MaxTemperature.
+	d.TemperatureConfidence = TemperatureConfidence_High
+
+	// Writer fills in the defaults for unset header fields.
+	want.Header.Version = 7
+	want.Header.ClassName = "CTravelTrakCEDoc"
+	want.Header.Unknown1 = make([]byte, 38)
+	want.Header.Unknown2 = make([]byte, 2)
+	want.Header.Unknown3 = make([]byte, 27)
+	d.Trailer = make([]byte, 8)
+
+	// In salt water, the profile resolution is 20/1025 m.
+	opts := []cmp.Option{
+		cmpopts.IgnoreUnexported(Header{}, Dive{}),
+		cmpopts.IgnoreFields(Dive{}, "Events"),
+		cmpopts.EquateApprox(0, 0.01),
+	}
+	if diff := cmp.Diff(want, got, opts...); diff != "" {
+		t.Errorf("round trip: results differ (-want/+got):\n%s", diff)
+	}
+}
+
+func TestWriter_GasMixes(t *testing.T) {
+	lb, err := ReadLogbook(bytes.NewReader(testFileData()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := lb.Dives[0]
+	d.GasMixes = append(d.GasMixes, GasMix{
+		Index:     8,
+		Time:      d.Profile[8].Time,
+		PercentO2: 80,
+		MaxPO2:    1.6,
+	})
+	want := d.GasMixes
+
+	got := roundTrip(t, lb)
+	if diff := cmp.Diff(want, got.Dives[0].GasMixes, cmpOpts...); diff != "" {
+		t.Errorf("round trip: gas mixes differ (-want/+got):\n%s", diff)
+	}
+}
+
+func TestWriter_GasMixIndex(t *testing.T) {
+	lb, err := ReadLogbook(bytes.NewReader(testFileData()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := lb.Dives[0]
+	if len(d.GasMixes) < 2 {
+		t.Fatalf("len(GasMixes) = %d, want at least 2", len(d.GasMixes))
+	}
+	d.GasMixes[1].Index = 8
+	d.GasMixes[1].Time = d.Profile[8].Time
+	want := d.GasMixes
+
+	got := roundTrip(t, lb)
+	if diff := cmp.Diff(want, got.Dives[0].GasMixes, cmpOpts...); diff != "" {
+		t.Errorf("round trip: gas mixes differ (-want/+got):\n%s", diff)
+	}
+}
+
+func TestWriter_SampleInterval(t *testing.T) {
+	var registry Registry
+	if err := registry.Set("0x00C0FFEE=Galileo Sol/20s"); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReader(bytes.NewReader(testFileData()))
+	r.Registry = &registry
+	want, err := r.ReadLogbook()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := want.Dives[0]
+	if got, want := d.SampleInterval, 20*time.Second; got != want {
+		t.Errorf("SampleInterval = %v, want %v", got, want)
+	}
+	if got, want := d.Profile[1].Time.Sub(d.Profile[0].Time), 20*time.Second; got != want {
+		t.Errorf("Profile[1].Time - Profile[0].Time = %v, want %v", got, want)
+	}
+
+	// Without the registry, the interval is inferred.
+	other, err := ReadLogbook(bytes.NewReader(testFileData()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := other.Dives[0].SampleInterval; got == 20*time.Second {
+		t.Errorf("SampleInterval = %v without a registry, want an inferred interval", got)
+	}
+
+	got := roundTripRegistry(t, want, &registry)
+	if diff := cmp.Diff(want, got, cmpOpts...); diff != "" {
+		t.Errorf("round trip: results differ (-want/+got):\n%s", diff)
+	}
+}
+
+func TestWriter_SampleIntervalError(t *testing.T) {
+	lb, err := ReadLogbook(bytes.NewReader(testFileData()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a registered interval, a changed interval cannot be stored.
+	d := lb.Dives[0]
+	d.SampleInterval = 30 * time.Second
+
+	err = WriteLogbook(io.Discard, lb)
+	var sie *SampleIntervalError
+	if !errors.As(err, &sie) || sie.SampleInterval != 30*time.Second {
+		t.Fatalf("WriteLogbook() = %v, want a *SampleIntervalError", err)
+	}
+
+	d.Device.SampleInterval = 30 * time.Second
+	if err := WriteLogbook(io.Discard, lb); err != nil {
+		t.Errorf("WriteLogbook() = %v", err)
+	}
+}
+
+func TestWriter_NoStop(t *testing.T) {
+	lb, err := ReadLogbook(bytes.NewReader(testFileData()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := lb.Dives[0]
+	for i := 5; i < len(d.Profile); i++ {
+		d.Profile[i].NoStopTime = 30 * time.Minute
+		d.Profile[i].MBNoStopTime = 12 * time.Minute
+	}
+	d.Profile[len(d.Profile)-1].NoStopTime = 0
+	want := d.Profile
+
+	got := roundTrip(t, lb)
+	if diff := cmp.Diff(want, got.Dives[0].Profile, cmpOpts...); diff != "" {
+		t.Errorf("round trip: profile differs (-want/+got):\n%s", diff)
+	}
+
+	d.Profile[5].NoStopTime = 256 * time.Minute
+	if err := WriteLogbook(io.Discard, lb); err == nil {
+		t.Error("WriteLogbook() = nil, want an error for a no-stop time of 256 minutes")
+	}
+}
@@ -1,14 +1,14 @@
 package main
 
 import (
-	"encoding/xml"
+	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
-	"time"
 
 	"github.com/octo/divelogs-go/divelogs"
+	"github.com/octo/divelogs-go/divemodel"
 	"github.com/octo/divelogs-go/smarttrak"
 )
 
@@ -32,11 +32,18 @@ func main() {
 
 type server struct {
 	templates *template.Template
+	// registry holds the dive computers configured with the DEVICES
+	// environment variable, e.g. "0x00C0FFEE=Galileo Sol/4s".
+	registry *smarttrak.Registry
 }
 
 func newServer() *server {
 	s := &server{
 		templates: template.Must(template.ParseGlob("templates/*.html")),
+		registry:  smarttrak.NewRegistry(),
+	}
+	if err := s.registry.Set(os.Getenv("DEVICES")); err != nil {
+		log.Fatal("DEVICES: ", err)
 	}
 
 	return s
@@ -70,26 +77,40 @@ func (s server) ASDCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := smarttrak.ReadHeader(file); err != nil {
-		log.Println("smarttrack.ReadHeader:", err)
+	rd := smarttrak.NewReader(file)
+	rd.Registry = s.registry
+	lb, err := rd.ReadLogbook()
+	if err != nil {
+		log.Println("smarttrack.ReadLogbook:", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	dive, err := smarttrak.ReadDive(file)
-	if err != nil {
-		log.Println("smarttrack.ReadDive:", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	page := asdPage{
+		Logbook:        lb,
+		DepthTolerance: smarttrak.DefaultDepthTolerance,
+	}
+	for _, dive := range lb.Dives {
+		_, report := divelogsData(dive)
+		page.Reports = append(page.Reports, report)
 	}
 
-	if err := s.templates.ExecuteTemplate(w, "dive.html", dive); err != nil {
+	if err := s.templates.ExecuteTemplate(w, "dive.html", page); err != nil {
 		log.Println("ExecuteTemplate:", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
 
+// asdPage is the data passed to the dive.html template.
+type asdPage struct {
+	*smarttrak.Logbook
+	// Reports holds the conversion report of each dive.
+	Reports []divemodel.Report
+	// DepthTolerance is passed to Dive.CheckProfile.
+	DepthTolerance float64
+}
+
 func (s server) Divelogs(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodPost {
 		s.DivelogsPost(w, r)
@@ -102,57 +123,65 @@ func (s server) Divelogs(w http.ResponseWriter, r *http.Request) {
 func (s server) DivelogsPost(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
-	if _, err := smarttrak.ReadHeader(r.Body); err != nil {
-		log.Println("smarttrack.ReadHeader:", err)
+	// The "tz" query parameter selects the time zone of DATE and TIME. By
+	// default the device's offset is used.
+	loc, err := divelogs.ParseLocation(r.URL.Query().Get("tz"))
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	dive, err := smarttrak.ReadDive(r.Body)
+	rd := smarttrak.NewReader(r.Body)
+	rd.Registry = s.registry
+	lb, err := rd.ReadLogbook()
 	if err != nil {
-		log.Println("smarttrack.ReadDive:", err)
+		log.Println("smarttrack.ReadLogbook:", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	d := divelogs.Data{
-		ID:                  0,
-		DiveNumber:          0,
-		Time:                dive.Time,
-		DiveDuration:        dive.Duration,
-		SurfaceDuration:     dive.SurfaceInterval,
-		MaxDepth:            dive.MaxDepth,
-		MeanDepth:           dive.AverageDepth,
-		Location:            "",
-		Site:                "",
-		Weather:             "",
-		Visibility:          "",
-		AirTemperature:      dive.AirTemperature,
-		MaxDepthTemperature: dive.MinTemperature,
-		DiveEndTemperature:  dive.DecoTemperature,
-		Partner:             "",
-		Boat:                "",
-		Cylinder:            divelogs.Cylinder{},
-		Weight:              0,
-		O2Percent:           float64(dive.PercentO2),
-		HEPercent:           float64(dive.PercentHE),
-		LogNotes:            "",
-		Latitude:            0,
-		Longitude:           0,
-		ZoomLevel:           0,
-		SampleInterval:      4 * time.Second,
-	}
-
-	for _, p := range dive.Profile {
-		d.Samples = append(d.Samples, divelogs.Sample{
-			Depth: p.Depth,
-		})
+	// A single dive is returned as <DIVELOGSDATA> for backwards
+	// compatibility, multiple dives as a logbook.
+	var (
+		v       interface{}
+		dl      divelogs.Logbook
+		reports []divemodel.Report
+	)
+	for _, dive := range lb.Dives {
+		d, report := divelogsData(dive)
+		dl.Add(d)
+		reports = append(reports, report)
+	}
+	if len(dl.Dives) == 1 {
+		v = dl.Dives[0]
+	} else {
+		v = dl
+	}
+
+	// The conversion report is returned in one X-Conversion-Report header
+	// per entry, e.g. "dive 1: dropped: TimeLimit: 1h39m0s".
+	for i, report := range reports {
+		for _, e := range report {
+			entry := fmt.Sprintf("dive %d: %v", i+1, e)
+			log.Println("conversion report:", entry)
+			w.Header().Add("X-Conversion-Report", entry)
+		}
 	}
 
 	w.Header().Set("Content-Type", "text/xml")
-	if err := xml.NewEncoder(w).Encode(d); err != nil {
-		log.Println("xml.Encoder.Encode:", err)
+	enc := divelogs.NewEncoder(w)
+	enc.Location = loc
+	if err := enc.Encode(v); err != nil {
+		log.Println("divelogs.Encoder.Encode:", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
+
+// divelogsData converts dive to the divelogs.de format. The returned report
+// lists the fields that did not survive the conversion.
+func divelogsData(dive *smarttrak.Dive) (divelogs.Data, divemodel.Report) {
+	m, report := smarttrak.ToModel(dive)
+	d, r := divelogs.FromModel(m)
+	return d, append(report, r...)
+}